@@ -0,0 +1,51 @@
+import { socket } from "$lib/store"
+
+const chunkSize = 256 * 1024
+
+function emit(event, data) {
+	return new Promise((resolve, reject) => {
+		socket.emit(event, data, (response) => {
+			if (response?.error) {
+				reject(new Error(response.error))
+			} else {
+				resolve(response)
+			}
+		})
+	})
+}
+
+function base64(buffer) {
+	const bytes = new Uint8Array(buffer)
+	let binary = ""
+	for (let i = 0; i < bytes.length; i += 0x8000) {
+		binary += String.fromCharCode(...bytes.subarray(i, i + 0x8000))
+	}
+	return btoa(binary)
+}
+
+// uploadBlob uploads a file or a blob in chunks, and resolves to the asset ID.
+export async function uploadBlob(blob, progress) {
+	const { upload } = await emit("upload asset start", {})
+	for (let offset = 0; offset < blob.size; offset += chunkSize) {
+		const chunk = await blob.slice(offset, offset + chunkSize).arrayBuffer()
+		await emit("upload asset chunk", { upload, data: base64(chunk) })
+		progress?.(Math.min(offset + chunkSize, blob.size), blob.size)
+	}
+	const { asset } = await emit("upload asset finish", upload)
+	return asset
+}
+
+// uploadDirectory uploads the files selected with a directory input, then
+// their manifest, and resolves to the manifest asset ID.
+export async function uploadDirectory(files, progress) {
+	const manifest = { files: [] }
+	let i = 0
+	for (const file of files) {
+		// Remove the name of the selected directory
+		const path = file.webkitRelativePath.split("/").slice(1).join("/")
+		const asset = await uploadBlob(file)
+		manifest.files.push({ path, asset })
+		progress?.(++i, files.length)
+	}
+	return uploadBlob(new Blob([JSON.stringify(manifest)], { type: "application/json" }))
+}
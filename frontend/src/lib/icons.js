@@ -1,18 +1,28 @@
 import add from "@fortawesome/fontawesome-free/svgs/solid/plus.svg?raw"
+import alternative from "@fortawesome/fontawesome-free/svgs/solid/shuffle.svg?raw"
+import archive from "@fortawesome/fontawesome-free/svgs/solid/file-zipper.svg?raw"
+import autostart from "@fortawesome/fontawesome-free/svgs/solid/rocket.svg?raw"
 import cancel from "@fortawesome/fontawesome-free/svgs/solid/rotate-left.svg?raw"
 import check from "@fortawesome/fontawesome-free/svgs/solid/check.svg?raw"
 import command from "@fortawesome/fontawesome-free/svgs/solid/terminal.svg?raw"
 import database from "@fortawesome/fontawesome-free/svgs/solid/database.svg?raw"
+import download from "@fortawesome/fontawesome-free/svgs/solid/download.svg?raw"
+import drive from "@fortawesome/fontawesome-free/svgs/solid/hard-drive.svg?raw"
 import edit from "@fortawesome/fontawesome-free/svgs/solid/pen-to-square.svg?raw"
 import error from "@fortawesome/fontawesome-free/svgs/solid/triangle-exclamation.svg?raw"
 import file from "@fortawesome/fontawesome-free/svgs/solid/file.svg?raw"
 import folder from "@fortawesome/fontawesome-free/svgs/regular/folder.svg?raw"
+import git from "@fortawesome/fontawesome-free/svgs/brands/git-alt.svg?raw"
 import group from "@fortawesome/fontawesome-free/svgs/solid/layer-group.svg?raw"
+import key from "@fortawesome/fontawesome-free/svgs/solid/key.svg?raw"
 import less from "@fortawesome/fontawesome-free/svgs/solid/caret-up.svg?raw"
 import link from "@fortawesome/fontawesome-free/svgs/solid/link.svg?raw"
 import logs from "@fortawesome/fontawesome-free/svgs/solid/bars-staggered.svg?raw"
+import module from "@fortawesome/fontawesome-free/svgs/solid/microchip.svg?raw"
 import more from "@fortawesome/fontawesome-free/svgs/solid/caret-down.svg?raw"
 import packages from "@fortawesome/fontawesome-free/svgs/solid/cubes.svg?raw"
+import pin from "@fortawesome/fontawesome-free/svgs/solid/thumbtack.svg?raw"
+import reboot from "@fortawesome/fontawesome-free/svgs/solid/power-off.svg?raw"
 import remove from "@fortawesome/fontawesome-free/svgs/solid/trash-can.svg?raw"
 import run from "@fortawesome/fontawesome-free/svgs/solid/gears.svg?raw"
 import save from "@fortawesome/fontawesome-free/svgs/solid/floppy-disk.svg?raw"
@@ -23,20 +33,30 @@ import variable from "@fortawesome/fontawesome-free/svgs/solid/code.svg?raw"
 
 export const icons = {
 	add,
+	alternative,
+	archive,
+	autostart,
 	cancel,
 	check,
 	command,
 	database,
+	download,
+	drive,
 	edit,
 	error,
 	file,
 	folder,
+	git,
 	group,
+	key,
 	less,
 	link,
 	logs,
+	module,
 	more,
 	packages,
+	pin,
+	reboot,
 	remove,
 	run,
 	save,
@@ -36,6 +36,13 @@ export function makefield(param, initial) {
 	let value
 	let validators
 	switch (param.type) {
+	case "asset":
+	case "asset directory":
+	case "asset or path":
+	case "asset directory or path":
+		value = initial??""
+		validators = [required()]
+		break
 	case "bool":
 		value = initial??false
 		validators = []
@@ -44,6 +51,10 @@ export function makefield(param, initial) {
 		value = initial??""
 		validators = [required(), filepathValidator()]
 		break
+	case "optional string":
+		value = initial??""
+		validators = []
+		break
 	case "string":
 		value = initial??""
 		validators = [required()]
@@ -1,3 +1,4 @@
+export { default as Asset } from "./Asset.svelte"
 export { default as Bool } from "./Bool.svelte"
 export { default as Filepath } from "./Filepath.svelte"
 export { default as String } from "./String.svelte"
@@ -31,10 +31,13 @@ export const command = derived(
 export const logs = writable([])
 export const logsReachedTheEnd = writable(false)
 
+export const rebootRequired = writable({"required": false, "packages": []})
+
 socket.on("connect", () => {
 	reconnecting.set(false)
 	socket.emit("groups", (response) => groups.set(response))
 	socket.emit("commands", (response) => commands.set(response))
+	socket.emit("reboot required", (response) => rebootRequired.set(response))
 	socket.emit("logs", (response) => {
 		logs.set(response.logs?.toReversed())
 		if (response.reached_the_end) {
@@ -111,6 +114,14 @@ socket.on("group status", (data) => {
 	})
 })
 
+socket.on("global variables", (data) => {
+	globalVariables.set(data)
+})
+
+socket.on("reboot required", (data) => {
+	rebootRequired.set(data)
+})
+
 socket.on("log", (log) => {
 	logs.update(logs => [log, ...logs])
 })
@@ -0,0 +1,73 @@
+// Package assets stores binary files and directory trees used by instructions.
+// Assets are content-addressed: their ID is the SHA-256 sum of their content.
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+const Dir = "/var/lib/keepakonf/assets"
+
+var (
+	ErrInvalidID = errors.New("invalid asset ID")
+
+	idRe = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// Path returns the path to the content of an asset.
+func Path(id string) (string, error) {
+	if !idRe.MatchString(id) {
+		return "", ErrInvalidID
+	}
+	return filepath.Join(Dir, id), nil
+}
+
+// Open opens the content of an asset.
+func Open(id string) (*os.File, error) {
+	path, err := Path(id)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Manifest is the content of a directory asset, which references an asset for
+// each file in the directory tree.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	// Path is relative to the root of the directory, with slashes
+	Path  string `json:"path"`
+	Asset string `json:"asset"`
+}
+
+// ReadManifest reads a directory asset.
+func ReadManifest(id string) (Manifest, error) {
+	path, err := Path(id)
+	if err != nil {
+		return Manifest{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return Manifest{}, err
+	}
+	for _, file := range manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return Manifest{}, errors.New("invalid path " + file.Path + " in manifest")
+		}
+		if !idRe.MatchString(file.Asset) {
+			return Manifest{}, ErrInvalidID
+		}
+	}
+	return manifest, nil
+}
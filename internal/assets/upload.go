@@ -0,0 +1,144 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const (
+	// Uploads which have not received any chunk for this delay are abandoned.
+	uploadTimeout = time.Hour
+	// Maximum size of an uploaded file
+	maxUploadSize = 4 << 30
+	// Maximum size of a single chunk
+	maxChunkSize = 1 << 20
+)
+
+var (
+	ErrUnknownUpload  = errors.New("unknown upload")
+	ErrChunkTooLarge  = fmt.Errorf("chunk larger than %d bytes", maxChunkSize)
+	ErrUploadTooLarge = fmt.Errorf("upload larger than %d bytes", maxUploadSize)
+
+	uploads   = map[string]*upload{}
+	uploadsMu sync.Mutex
+)
+
+type upload struct {
+	mu    sync.Mutex
+	file  *os.File
+	hash  hash.Hash
+	size  int64
+	timer *time.Timer
+}
+
+func uploadsDir() string {
+	return filepath.Join(Dir, "uploads")
+}
+
+// StartUpload prepares a new upload and returns its ID.
+func StartUpload() (string, error) {
+	uploadsMu.Lock()
+	defer uploadsMu.Unlock()
+
+	if err := os.MkdirAll(uploadsDir(), 0o700); err != nil {
+		return "", err
+	}
+
+	id := xid.New().String()
+	f, err := os.Create(filepath.Join(uploadsDir(), id))
+	if err != nil {
+		return "", err
+	}
+
+	uploads[id] = &upload{
+		file: f,
+		hash: sha256.New(),
+		timer: time.AfterFunc(uploadTimeout, func() {
+			CancelUpload(id)
+		}),
+	}
+	return id, nil
+}
+
+// WriteChunk appends data to an upload.
+func WriteChunk(uploadID string, data []byte) error {
+	uploadsMu.Lock()
+	up, ok := uploads[uploadID]
+	uploadsMu.Unlock()
+	if !ok {
+		return ErrUnknownUpload
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+
+	switch {
+	case len(data) > maxChunkSize:
+		CancelUpload(uploadID)
+		return ErrChunkTooLarge
+	case up.size+int64(len(data)) > maxUploadSize:
+		CancelUpload(uploadID)
+		return ErrUploadTooLarge
+	}
+
+	up.timer.Reset(uploadTimeout)
+	if _, err := up.file.Write(data); err != nil {
+		CancelUpload(uploadID)
+		return err
+	}
+	up.size += int64(len(data))
+	up.hash.Write(data)
+	return nil
+}
+
+// FinishUpload stores the uploaded content as an asset and returns its ID.
+func FinishUpload(uploadID string) (string, error) {
+	uploadsMu.Lock()
+	up, ok := uploads[uploadID]
+	delete(uploads, uploadID)
+	uploadsMu.Unlock()
+	if !ok {
+		return "", ErrUnknownUpload
+	}
+	up.timer.Stop()
+	defer os.Remove(up.file.Name())
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+
+	if err := up.file.Close(); err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(up.hash.Sum(nil))
+	path, err := Path(id)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(up.file.Name(), path); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// CancelUpload abandons an upload.
+func CancelUpload(uploadID string) {
+	uploadsMu.Lock()
+	up, ok := uploads[uploadID]
+	delete(uploads, uploadID)
+	uploadsMu.Unlock()
+	if ok {
+		up.timer.Stop()
+		up.file.Close()
+		os.Remove(up.file.Name())
+	}
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"encoding/base64"
+
+	"github.com/willoma/keepakonf/internal/assets"
+	"github.com/willoma/keepakonf/internal/log"
+)
+
+// uploadAssetStart starts a chunked upload. The response contains the upload
+// ID, to be used with the following chunks.
+func (c *client) uploadAssetStart(a ...any) {
+	uploadID, err := assets.StartUpload()
+	if err != nil {
+		log.Error(err, "Could not start asset upload")
+		callback(a, map[string]any{"error": err.Error()})
+		return
+	}
+	callback(a, map[string]any{"upload": uploadID})
+}
+
+// uploadAssetChunk receives a base64-encoded chunk of an upload.
+func (c *client) uploadAssetChunk(a ...any) {
+	if len(a) == 0 {
+		return
+	}
+
+	mapped, ok := a[0].(map[string]any)
+	if !ok {
+		return
+	}
+	uploadID, _ := mapped["upload"].(string)
+	encoded, _ := mapped["data"].(string)
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		assets.CancelUpload(uploadID)
+		callback(a, map[string]any{"error": err.Error()})
+		return
+	}
+
+	if err := assets.WriteChunk(uploadID, data); err != nil {
+		log.Error(err, "Could not write asset chunk")
+		callback(a, map[string]any{"error": err.Error()})
+		return
+	}
+	callback(a, map[string]any{})
+}
+
+// uploadAssetFinish stores the uploaded content. The response contains the
+// asset ID.
+func (c *client) uploadAssetFinish(a ...any) {
+	if len(a) == 0 {
+		return
+	}
+
+	uploadID, ok := a[0].(string)
+	if !ok {
+		return
+	}
+
+	assetID, err := assets.FinishUpload(uploadID)
+	if err != nil {
+		log.Error(err, "Could not store asset")
+		callback(a, map[string]any{"error": err.Error()})
+		return
+	}
+	callback(a, map[string]any{"asset": assetID})
+}
@@ -31,8 +31,13 @@ func Serve(s *socket.Socket, io socket.NamespaceInterface, data *data.Data) {
 
 	c.On("logs", c.logs)
 
+	c.On("upload asset start", c.uploadAssetStart)
+	c.On("upload asset chunk", c.uploadAssetChunk)
+	c.On("upload asset finish", c.uploadAssetFinish)
+
 	c.On("users", c.users)
 	c.On("global variables", c.globalVariables)
+	c.On("reboot required", c.rebootRequired)
 }
 
 func callback(request []any, response ...any) {
@@ -15,5 +15,9 @@ func (c *client) users(a ...any) {
 }
 
 func (c *client) globalVariables(a ...any) {
-	callback(a, variables.Global)
+	callback(a, variables.Global())
+}
+
+func (c *client) rebootRequired(a ...any) {
+	callback(a, external.RebootRequiredState())
 }
@@ -12,6 +12,12 @@ type Command interface {
 	Apply() bool
 }
 
+// groupMember is implemented by commands which need to know the group they
+// belong to.
+type groupMember interface {
+	setGroup(id string)
+}
+
 type constructor func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command
 
 type definition struct {
@@ -19,8 +25,13 @@ type definition struct {
 	constructor constructor
 }
 
+// migration converts the parameters of a removed command to a replacement
+// command.
+type migration func(params map[string]any) (name string, newParams map[string]any)
+
 var (
-	byName = map[string]definition{}
+	byName     = map[string]definition{}
+	migrations = map[string]migration{}
 )
 
 func register(name, icon, description string, parameters ParamsDesc, c constructor) struct{} {
@@ -28,11 +39,47 @@ func register(name, icon, description string, parameters ParamsDesc, c construct
 	return struct{}{}
 }
 
-func Init(name string, params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+func registerMigration(name string, m migration) struct{} {
+	migrations[name] = m
+	return struct{}{}
+}
+
+// Migrate returns the replacement command and parameters for removed
+// commands, or name and params unchanged.
+func Migrate(name string, params map[string]any) (string, map[string]any) {
+	m, ok := migrations[name]
+	if !ok {
+		return name, params
+	}
+	return m(params)
+}
+
+func Init(name, group string, params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
 	def, ok := byName[name]
 	if !ok {
 		return nil
 	}
 	def.description.Parameters.ensureTyped(params)
-	return def.constructor(params, vars, msg)
+	cmd := def.constructor(params, vars, msg)
+	if member, ok := cmd.(groupMember); ok {
+		member.setGroup(group)
+	}
+	return cmd
+}
+
+// stepReceiver returns a receiver for an intermediate external command, which
+// forwards running and failed statuses to msg, so that the final status can
+// be sent by the caller once all commands have been run.
+func stepReceiver(msg status.SendStatus, action string) func(status.Status, string, status.Detail) {
+	return func(s status.Status, info string, detail status.Detail) {
+		if info == "" {
+			info = action
+		}
+		switch s {
+		case status.StatusRunning, status.StatusApplied:
+			msg(status.StatusRunning, info, detail, nil)
+		case status.StatusFailed:
+			msg(status.StatusFailed, "Failed: "+info, detail, nil)
+		}
+	}
 }
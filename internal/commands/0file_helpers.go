@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/willoma/keepakonf/internal/assets"
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/log"
+)
+
+// parseMode parses an octal file mode, returning def if mode is empty.
+func parseMode(mode string, def fs.FileMode) (fs.FileMode, error) {
+	if mode == "" {
+		return def, nil
+	}
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %q", mode)
+	}
+	return fs.FileMode(parsed) & fs.ModePerm, nil
+}
+
+// sha256File returns the hexadecimal SHA-256 sum of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// userHomePath returns the path to rel in the home directory of username. If
+// the user does not exist, the path is in the temporary directory instead, so
+// that the watchers have something to watch.
+func userHomePath(username, rel string) string {
+	userData, err := external.GetUser(username)
+	if err != nil {
+		log.Errorf(err, "Could not get user information for %q", username)
+		return filepath.Join(os.TempDir(), rel)
+	}
+
+	return filepath.Join(userData.Home, rel)
+}
+
+// chownUser changes the ownership of path to username and its primary group.
+func chownUser(path, username string) error {
+	userData, err := external.GetUser(username)
+	if err != nil {
+		return fmt.Errorf("could not get user information for %q: %w", username, err)
+	}
+	return os.Chown(path, userData.ID, userData.GID)
+}
+
+// chownUserFile changes the ownership of an open file to username and its
+// primary group.
+func chownUserFile(f *os.File, username string) error {
+	userData, err := external.GetUser(username)
+	if err != nil {
+		return fmt.Errorf("could not get user information for %q: %w", username, err)
+	}
+	return f.Chown(userData.ID, userData.GID)
+}
+
+// mkdirAllOwned creates dir and its missing parents, giving the created
+// directories to owner if it is not empty.
+func mkdirAllOwned(dir string, mode fs.FileMode, owner string) error {
+	created := []string{}
+	for parent := dir; ; parent = filepath.Dir(parent) {
+		if _, err := os.Stat(parent); !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		created = append(created, parent)
+	}
+
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+
+	if owner == "" {
+		return nil
+	}
+	for _, parent := range created {
+		if err := chownUser(parent, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readOptionalFile returns the content of the file at path, or "" if it does
+// not exist.
+func readOptionalFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(content), nil
+}
+
+// writeOrRemoveFile writes content to the file at path, or removes the file if
+// content is empty.
+func writeOrRemoveFile(path, content string, mode fs.FileMode) error {
+	if content == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+// checkNotSymlink returns an error if path is a symbolic link. A missing path
+// is not an error.
+func checkNotSymlink(path string) error {
+	finfo, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if finfo.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("%q is a symbolic link", path)
+	}
+	return nil
+}
+
+// checkNoSymlinkParent returns an error if a parent of rel inside dir is a
+// symbolic link, which could make a path escape dir, for instance with an
+// archive containing "a -> /etc" then "a/passwd", or with a link created by
+// the owner of a directory synchronized as root.
+func checkNoSymlinkParent(dir, rel string) error {
+	for parent := filepath.Dir(rel); parent != "."; parent = filepath.Dir(parent) {
+		finfo, err := os.Lstat(filepath.Join(dir, parent))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if finfo.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%q is inside symbolic link %q", rel, parent)
+		}
+	}
+	return nil
+}
+
+// fileOwnership returns the owner, group and permissions of path.
+func fileOwnership(path string) (uid, gid int, mode fs.FileMode, err error) {
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	stat, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("could not get ownership of %q", path)
+	}
+	return int(stat.Uid), int(stat.Gid), finfo.Mode().Perm(), nil
+}
+
+// formatSize returns a human-readable size.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// installAsset copies an asset to path through a temporary file in the same
+// directory, so that path is replaced atomically.
+func installAsset(assetID, path string, mode fs.FileMode, owner string) error {
+	src, err := assets.Open(assetID)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return installFile(src, path, mode, owner)
+}
+
+// installFile copies src to path through a temporary file in the same
+// directory, so that path is replaced atomically. Mode and ownership are set
+// on the open file, and a symbolic link at path is replaced, not followed.
+func installFile(src io.Reader, path string, mode fs.FileMode, owner string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil && owner != "" {
+		err = chownUserFile(tmp, owner)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
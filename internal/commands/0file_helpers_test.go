@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInstallFileReplacesSymlink(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("original"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := installFile(strings.NewReader("installed"), path, 0o640, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if content, _ := os.ReadFile(target); string(content) != "original" {
+		t.Errorf("symbolic link target overwritten with %q", content)
+	}
+	finfo, err := os.Lstat(path)
+	if err != nil || !finfo.Mode().IsRegular() {
+		t.Fatalf("expected a regular file replacing the symbolic link, got %v, %v", finfo, err)
+	}
+	if finfo.Mode().Perm() != 0o640 {
+		t.Errorf("mode is %04o, expected 0640", finfo.Mode().Perm())
+	}
+	if content, _ := os.ReadFile(path); string(content) != "installed" {
+		t.Errorf("content is %q", content)
+	}
+}
+
+func TestCheckNoSymlinkParent(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), filepath.Join(dir, "a", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		rel   string
+		valid bool
+	}{
+		{"file", true},
+		{"a/b/file", true},
+		{"a/missing/file", true},
+		{"a/link", true},
+		{"a/link/file", false},
+		{"a/link/sub/file", false},
+	} {
+		if err := checkNoSymlinkParent(dir, filepath.FromSlash(tc.rel)); (err == nil) != tc.valid {
+			t.Errorf("checkNoSymlinkParent(%q) returned %v", tc.rel, err)
+		}
+	}
+}
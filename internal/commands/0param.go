@@ -3,12 +3,17 @@ package commands
 type ParamType string
 
 const (
-	ParamTypeBool        ParamType = "bool"
-	ParamTypeFilePath    ParamType = "filepath"
-	ParamTypeString      ParamType = "string"
-	ParamTypeStringArray ParamType = "[string]"
-	ParamTypeText        ParamType = "text"
-	ParamTypeUsername    ParamType = "username"
+	ParamTypeAsset          ParamType = "asset"
+	ParamTypeAssetDir       ParamType = "asset directory"
+	ParamTypeAssetOrPath    ParamType = "asset or path"
+	ParamTypeAssetDirOrPath ParamType = "asset directory or path"
+	ParamTypeBool           ParamType = "bool"
+	ParamTypeFilePath       ParamType = "filepath"
+	ParamTypeOptString      ParamType = "optional string"
+	ParamTypeString         ParamType = "string"
+	ParamTypeStringArray    ParamType = "[string]"
+	ParamTypeText           ParamType = "text"
+	ParamTypeUsername       ParamType = "username"
 )
 
 type ParamDesc struct {
@@ -42,7 +47,7 @@ func (p ParamDesc) extractTyped(src map[string]any) any {
 		}
 		return b
 
-	case ParamTypeFilePath, ParamTypeString, ParamTypeText, ParamTypeUsername:
+	case ParamTypeAsset, ParamTypeAssetDir, ParamTypeAssetOrPath, ParamTypeAssetDirOrPath, ParamTypeFilePath, ParamTypeOptString, ParamTypeString, ParamTypeText, ParamTypeUsername:
 		if !ok {
 			return ""
 		}
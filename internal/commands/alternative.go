@@ -0,0 +1,162 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = register(
+	"alternative",
+	"alternative",
+	"Select an alternative with update-alternatives",
+	ParamsDesc{
+		{"name", "Alternative name", ParamTypeString},
+		{"path", "Selected path", ParamTypeFilePath},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &alternative{
+			msg:  msg,
+			vars: vars,
+			name: params["name"].(string),
+			path: params["path"].(string),
+		}
+	},
+)
+
+type alternative struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	name string
+	path string
+
+	applying   atomic.Bool
+	adminClose func()
+	linkClose  func()
+	closeChan  chan struct{}
+}
+
+func (a *alternative) UpdateVariables(vars variables.Variables) {
+	if a.vars.Update(vars) {
+		a.Stop()
+		a.Watch()
+	}
+}
+
+func (a *alternative) Watch() {
+	name := a.vars.Replace(a.name)
+
+	adminChan, adminClose := external.WatchFile(external.AlternativeAdminPath(name))
+	a.adminClose = adminClose
+
+	linkChan, linkClose := external.WatchFile(external.AlternativeLinkPath(name))
+	a.linkClose = linkClose
+
+	closeChan := make(chan struct{})
+	a.closeChan = closeChan
+
+	go func() {
+		for {
+			select {
+			case <-adminChan:
+			case <-linkChan:
+			case <-closeChan:
+				return
+			}
+			if a.applying.Load() {
+				// No update if it is currently applying
+				continue
+			}
+			a.msg(a.check())
+		}
+	}()
+}
+
+func (a *alternative) check() (status.Status, string, status.Detail, variables.Variables) {
+	name := a.vars.Replace(a.name)
+	path := a.vars.Replace(a.path)
+
+	alt, err := external.GetAlternative(name)
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not read alternative %q", name), status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"Candidate", "Priority", "Status"},
+	}
+
+	var isCandidate bool
+	for _, candidate := range alt.Candidates {
+		var (
+			rowStatus status.Status
+			content   string
+		)
+		switch {
+		case candidate.Path == path && candidate.Path == alt.Selected:
+			isCandidate = true
+			rowStatus, content = status.StatusApplied, "Selected"
+		case candidate.Path == path:
+			isCandidate = true
+			rowStatus, content = status.StatusTodo, "To select"
+		case candidate.Path == alt.Selected:
+			rowStatus, content = status.StatusTodo, "Selected"
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: candidate.Path},
+			status.TableCell{Status: status.StatusNone, Content: strconv.Itoa(candidate.Priority)},
+			status.TableCell{Status: rowStatus, Content: content},
+		)
+	}
+
+	switch {
+	case !isCandidate:
+		return status.StatusFailed, fmt.Sprintf("%q is not a candidate for alternative %q", path, name), &result, nil
+	case alt.Selected != path:
+		return status.StatusTodo, fmt.Sprintf("Need to select %q for alternative %q", path, name), &result, nil
+	default:
+		return status.StatusApplied, fmt.Sprintf("%q is selected for alternative %q", path, name), &result, nil
+	}
+}
+
+func (a *alternative) Stop() {
+	if a.adminClose != nil {
+		a.adminClose()
+	}
+	if a.linkClose != nil {
+		a.linkClose()
+	}
+	if a.closeChan != nil {
+		close(a.closeChan)
+		a.closeChan = nil
+	}
+}
+
+func (a *alternative) Apply() bool {
+	name := a.vars.Replace(a.name)
+	path := a.vars.Replace(a.path)
+
+	a.applying.Store(true)
+	defer a.applying.Store(false)
+
+	return external.UpdateAlternatives(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = fmt.Sprintf("Selecting %q for alternative %q", path, name)
+				case status.StatusApplied:
+					info = fmt.Sprintf("Selected %q for alternative %q", path, name)
+				case status.StatusFailed:
+					info = fmt.Sprintf("Failed selecting %q for alternative %q", path, name)
+				}
+			}
+			a.msg(s, info, detail, nil)
+		},
+		"--set", name, path,
+	)
+}
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = register(
+	"apt autoremove",
+	"packages",
+	"Remove packages which are not needed anymore",
+	ParamsDesc{
+		{"purge", "Purge the packages", ParamTypeBool},
+		{"keep", "Packages to always keep", ParamTypeStringArray},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &aptAutoremove{
+			msg:   msg,
+			vars:  vars,
+			purge: params["purge"].(bool),
+			keep:  params["keep"].([]string),
+		}
+	},
+)
+
+type aptAutoremove struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	purge bool
+	keep  []string
+
+	needToRemove []string
+	needToKeep   []string
+
+	applying atomic.Bool
+	close    []func()
+}
+
+func (a *aptAutoremove) UpdateVariables(vars variables.Variables) {
+	if a.vars.Update(vars) {
+		a.update()
+	}
+}
+
+func (a *aptAutoremove) Watch() {
+	dpkgSignals, dpkgClose := external.DpkgListen()
+	// Marking packages as automatically installed changes what is autoremoved
+	autoSignals, autoClose := external.AptAutoListen()
+	a.close = []func(){dpkgClose, autoClose}
+
+	go func() {
+		for {
+			select {
+			case _, ok := <-dpkgSignals:
+				if !ok {
+					return
+				}
+			case _, ok := <-autoSignals:
+				if !ok {
+					return
+				}
+			}
+			a.update()
+		}
+	}()
+}
+
+func (a *aptAutoremove) update() {
+	removals, err := external.AptSimulateRemovals("autoremove")
+	if err != nil {
+		if !a.applying.Load() {
+			a.msg(status.StatusFailed, "Could not list packages to autoremove", status.Error(err.Error()), nil)
+		}
+		return
+	}
+
+	needToRemove := []string{}
+	needToKeep := []string{}
+
+	table := status.Table{
+		Header: []string{"Package", "Installed version", "Action"},
+	}
+	keep := a.vars.ReplaceSlice(a.keep)
+
+	for _, removal := range removals {
+		if slices.Contains(keep, removal.Name) {
+			needToKeep = append(needToKeep, removal.Name)
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: removal.Name},
+				status.TableCell{Status: status.StatusNone, Content: removal.Version},
+				status.TableCell{Status: status.StatusTodo, Content: "Mark as manually installed"},
+			)
+			continue
+		}
+		needToRemove = append(needToRemove, removal.Name)
+		table.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: removal.Name},
+			status.TableCell{Status: status.StatusNone, Content: removal.Version},
+			status.TableCell{Status: status.StatusTodo, Content: "Remove"},
+		)
+	}
+
+	msgStatus := status.StatusApplied
+	var info string
+	switch {
+	case len(needToRemove) > 0:
+		msgStatus = status.StatusTodo
+		info = "Need to remove " + strings.Join(needToRemove, ", ")
+	case len(needToKeep) > 0:
+		msgStatus = status.StatusTodo
+		info = "Need to keep " + strings.Join(needToKeep, ", ")
+	default:
+		info = "No package to autoremove"
+	}
+
+	a.needToRemove = needToRemove
+	a.needToKeep = needToKeep
+	if !a.applying.Load() {
+		if len(table.Rows) == 0 {
+			a.msg(msgStatus, info, nil, nil)
+		} else {
+			a.msg(msgStatus, info, &table, nil)
+		}
+	}
+}
+
+func (a *aptAutoremove) Stop() {
+	for _, close := range a.close {
+		close()
+	}
+	a.close = nil
+}
+
+func (a *aptAutoremove) Apply() bool {
+	needToRemoveMsg := strings.Join(a.needToRemove, ", ")
+
+	a.applying.Store(true)
+	defer a.applying.Store(false)
+
+	// Packages marked as manually installed are never autoremoved
+	if len(a.needToKeep) > 0 {
+		if !external.AptMark(stepReceiver(a.msg, "Marking "+strings.Join(a.needToKeep, ", ")+" as manually installed"), "manual", a.needToKeep...) {
+			return false
+		}
+	}
+
+	if len(a.needToRemove) == 0 {
+		a.msg(status.StatusApplied, "No package to autoremove", nil, nil)
+		return true
+	}
+
+	// Never remove packages which were not shown to the user
+	removals, err := external.AptSimulateRemovals("autoremove")
+	if err != nil {
+		a.msg(status.StatusFailed, "Could not list packages to autoremove", status.Error(err.Error()), nil)
+		return false
+	}
+	names := make([]string, len(removals))
+	for i, removal := range removals {
+		names[i] = removal.Name
+	}
+	slices.Sort(names)
+	expected := slices.Clone(a.needToRemove)
+	slices.Sort(expected)
+	if !slices.Equal(names, expected) {
+		a.msg(
+			status.StatusFailed,
+			"Packages to autoremove changed, not removing anything",
+			status.Error("expected "+strings.Join(expected, ", ")+", got "+strings.Join(names, ", ")),
+			nil,
+		)
+		return false
+	}
+
+	args := []string{}
+	if a.purge {
+		args = append(args, "--purge")
+	}
+
+	return external.AptGet(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = "Removing " + needToRemoveMsg
+				case status.StatusApplied:
+					info = "Successfully removed " + needToRemoveMsg
+				case status.StatusFailed:
+					info = "Failed removing " + needToRemoveMsg
+				}
+			}
+			a.msg(s, info, detail, nil)
+		},
+		"autoremove", args...,
+	)
+}
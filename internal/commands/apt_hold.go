@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = register(
+	"apt hold",
+	"pin",
+	"Hold packages at their current version",
+	ParamsDesc{
+		{"packages", "Packages to hold", ParamTypeStringArray},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &aptHold{
+			msg:      msg,
+			vars:     vars,
+			packages: params["packages"].([]string),
+		}
+	},
+)
+
+type aptHold struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	packages []string
+
+	needToHold []string
+
+	applying atomic.Bool
+	close    func()
+}
+
+func (a *aptHold) UpdateVariables(vars variables.Variables) {
+	if a.vars.Update(vars) {
+		a.update(external.DpkgPackages())
+	}
+}
+
+func (a *aptHold) Watch() {
+	signals, close := external.DpkgListen()
+	a.close = close
+
+	go func() {
+		for knownPackages := range signals {
+			a.update(knownPackages)
+		}
+	}()
+}
+
+func (a *aptHold) update(knownPackages map[string]external.DpkgPackage) {
+	needToHold := []string{}
+	unknown := []string{}
+
+	msgStatus := status.StatusApplied
+	table := status.Table{
+		Header: []string{"Package", "Installed version", "Selection"},
+	}
+	pkgs := a.vars.ReplaceSlice(a.packages)
+
+	for _, pkg := range pkgs {
+		if pkg == "" {
+			continue
+		}
+		info, ok := knownPackages[pkg]
+		switch {
+		case !ok:
+			unknown = append(unknown, pkg)
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: pkg},
+				status.TableCell{Status: status.StatusFailed, Content: "None"},
+				status.TableCell{Status: status.StatusFailed, Content: "Unknown"},
+			)
+		case info.Held():
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: pkg},
+				status.TableCell{Status: status.StatusNone, Content: info.Version},
+				status.TableCell{Status: status.StatusApplied, Content: info.Want},
+			)
+		default:
+			needToHold = append(needToHold, pkg)
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: pkg},
+				status.TableCell{Status: status.StatusNone, Content: info.Version},
+				status.TableCell{Status: status.StatusTodo, Content: info.Want},
+			)
+		}
+	}
+
+	var info string
+	if len(unknown) > 0 {
+		msgStatus = status.StatusFailed
+		info = "Unknown packages " + strings.Join(unknown, ", ")
+	} else if len(needToHold) > 0 {
+		msgStatus = status.StatusTodo
+		info = "Need to hold " + strings.Join(needToHold, ", ")
+	} else if len(pkgs) == 1 {
+		info = "Package " + pkgs[0] + " held"
+	} else {
+		info = "Packages " + strings.Join(pkgs, ", ") + " held"
+	}
+
+	a.needToHold = needToHold
+	if !a.applying.Load() {
+		a.msg(msgStatus, info, &table, nil)
+	}
+}
+
+func (a *aptHold) Stop() {
+	if a.close != nil {
+		a.close()
+	}
+}
+
+func (a *aptHold) Apply() bool {
+	if len(a.needToHold) == 0 {
+		// Nothing to hold while not applied: some packages are unknown
+		a.msg(status.StatusFailed, "No package to hold, some packages are unknown", nil, nil)
+		return false
+	}
+
+	needToHoldMsg := strings.Join(a.needToHold, ", ")
+
+	a.applying.Store(true)
+	defer a.applying.Store(false)
+
+	return external.AptMark(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = "Holding " + needToHoldMsg
+				case status.StatusApplied:
+					info = "Successfully held " + needToHoldMsg
+				case status.StatusFailed:
+					info = "Failed holding " + needToHoldMsg
+				}
+			}
+			a.msg(s, info, detail, nil)
+		},
+		"hold", a.needToHold...,
+	)
+}
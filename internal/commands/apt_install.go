@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/willoma/keepakonf/internal/external"
@@ -14,36 +16,154 @@ var _ = register(
 	"packages",
 	"Install packages using apt",
 	ParamsDesc{
-		{"packages", "Packages to install", ParamTypeStringArray},
+		{"packages", "Packages to install (name[:arch][=version])", ParamTypeStringArray},
+		{"release", "Target release", ParamTypeOptString},
+		{"norecommends", "Do not install recommended packages", ParamTypeBool},
 	},
 	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
 		return &aptInstall{
-			msg:      msg,
-			vars:     vars,
-			packages: params["packages"].([]string),
+			msg:          msg,
+			vars:         vars,
+			packages:     params["packages"].([]string),
+			release:      params["release"].(string),
+			noRecommends: params["norecommends"].(bool),
 		}
 	},
 )
 
+// aptInstallRequested lists the packages requested by each apt install
+// instruction, by group, so that apt remove does not remove them.
+var (
+	aptInstallRequested   = map[string]map[*aptInstall][]string{}
+	aptInstallRequestedMu sync.Mutex
+)
+
+// aptPackagesRequestedByOtherGroups returns the packages requested by apt
+// install instructions in groups other than group.
+func aptPackagesRequestedByOtherGroups(group string) map[string]struct{} {
+	aptInstallRequestedMu.Lock()
+	defer aptInstallRequestedMu.Unlock()
+
+	requested := map[string]struct{}{}
+	for g, instructions := range aptInstallRequested {
+		if g == group {
+			continue
+		}
+		for _, pkgs := range instructions {
+			for _, pkg := range pkgs {
+				requested[pkg] = struct{}{}
+			}
+		}
+	}
+	return requested
+}
+
 type aptInstall struct {
-	msg  status.SendStatus
-	vars variables.Variables
+	msg   status.SendStatus
+	vars  variables.Variables
+	group string
 
-	packages []string
+	packages     []string
+	release      string
+	noRecommends bool
 
 	needToInstall []string
+	downgrade     bool
 
 	applying atomic.Bool
 	close    func()
 }
 
+// aptPackageSpec is a package to install, in the name[:arch][=version] form.
+type aptPackageSpec struct {
+	name    string
+	arch    string
+	version string
+}
+
+func parseAptPackageSpec(spec string) aptPackageSpec {
+	var p aptPackageSpec
+	spec, p.version, _ = strings.Cut(spec, "=")
+	p.name, p.arch, _ = strings.Cut(spec, ":")
+	return p
+}
+
+// key returns the package name, qualified with its architecture if needed.
+func (p aptPackageSpec) key() string {
+	if p.arch == "" {
+		return p.name
+	}
+	return p.name + ":" + p.arch
+}
+
+func (p aptPackageSpec) String() string {
+	if p.version == "" {
+		return p.key()
+	}
+	return p.key() + "=" + p.version
+}
+
+func (a *aptInstall) setGroup(id string) {
+	a.group = id
+}
+
+// specs returns the requested packages, without duplicates.
+func (a *aptInstall) specs() []aptPackageSpec {
+	specs := []aptPackageSpec{}
+	keys := map[string]struct{}{}
+	for _, pkg := range a.vars.ReplaceSlice(a.packages) {
+		if pkg == "" {
+			continue
+		}
+		spec := parseAptPackageSpec(pkg)
+		if spec.arch == external.DpkgArchitecture() {
+			// Same package as without architecture, which is how apt names it
+			spec.arch = ""
+		}
+		if _, ok := keys[spec.key()]; ok {
+			// Only the first occurrence of a package is kept
+			continue
+		}
+		keys[spec.key()] = struct{}{}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// register makes the requested packages known to apt remove instructions.
+func (a *aptInstall) register() {
+	requested := []string{}
+	for _, spec := range a.specs() {
+		requested = append(requested, spec.key())
+	}
+
+	aptInstallRequestedMu.Lock()
+	defer aptInstallRequestedMu.Unlock()
+	if aptInstallRequested[a.group] == nil {
+		aptInstallRequested[a.group] = map[*aptInstall][]string{}
+	}
+	aptInstallRequested[a.group][a] = requested
+}
+
+func (a *aptInstall) unregister() {
+	aptInstallRequestedMu.Lock()
+	defer aptInstallRequestedMu.Unlock()
+	delete(aptInstallRequested[a.group], a)
+	if len(aptInstallRequested[a.group]) == 0 {
+		delete(aptInstallRequested, a.group)
+	}
+}
+
 func (a *aptInstall) UpdateVariables(vars variables.Variables) {
 	if a.vars.Update(vars) {
+		a.register()
 		a.update(external.AptCachePackages())
 	}
 }
 
 func (a *aptInstall) Watch() {
+	a.register()
+
 	signals, close := external.AptCacheListen()
 	a.close = close
 
@@ -57,55 +177,119 @@ func (a *aptInstall) Watch() {
 func (a *aptInstall) update(knownPackages map[string]external.DpkgPackage) {
 	needToInstall := []string{}
 	unknown := []string{}
+	var downgrade bool
+
+	specs := a.specs()
+
+	// Candidate versions from the target release, for packages without a
+	// requested version
+	release := a.vars.Replace(a.release)
+	candidates := map[string]external.AptCandidate{}
+	if release != "" {
+		names := []string{}
+		for _, spec := range specs {
+			if spec.version == "" && !slices.Contains(names, spec.key()) {
+				names = append(names, spec.key())
+			}
+		}
+		if len(names) > 0 {
+			var err error
+			candidates, err = external.AptCandidates(release, names...)
+			if err != nil {
+				if !a.applying.Load() {
+					a.msg(status.StatusFailed, "Could not get available versions from "+release, status.Error(err.Error()), nil)
+				}
+				return
+			}
+		}
+	}
 
 	msgStatus := status.StatusApplied
 	table := status.Table{
-		Header: []string{"Package", "Installed Version", "Available Version"},
+		Header: []string{"Package", "Installed Version", "Requested Version", "Available Version"},
 	}
 
-	pkgs := a.vars.ReplaceSlice(a.packages)
+	for _, spec := range specs {
+		pkg := spec.key()
 
-	for _, pkg := range pkgs {
-		if pkg == "" {
-			continue
-		}
 		info, ok := knownPackages[pkg]
+		if !ok && spec.arch != "" {
+			// The package is not known for this architecture yet, only for
+			// the native one
+			var native external.DpkgPackage
+			native, ok = knownPackages[spec.name]
+			info = native
+			if native.Architecture != spec.arch && native.Architecture != "all" {
+				info = external.DpkgPackage{Name: spec.name, Architecture: spec.arch, AvailableVersion: native.AvailableVersion}
+			}
+		}
+
+		available := info.AvailableVersion
+		if candidate, ok := candidates[pkg]; ok {
+			available = candidate.Version
+		} else if candidate, ok := candidates[spec.name]; ok {
+			// apt-cache does not qualify packages of the native architecture
+			available = candidate.Version
+		}
+
+		requested := spec.version
+		expected := spec.version
+		if requested == "" {
+			requested = "Latest"
+			expected = available
+		}
+
+		availableCell := status.TableCell{Status: status.StatusNone, Content: available}
+		if available == "" {
+			availableCell.Content = "None"
+		}
+
+		upToDate := ok && info.Installed && info.Version == expected
+		newerInstalled := false
+		if ok && info.Installed && !upToDate && spec.version != "" {
+			// Only needed to know if the requested version is a downgrade
+			var err error
+			if newerInstalled, err = external.DpkgCompareVersions(info.Version, "gt", expected); err != nil {
+				if !a.applying.Load() {
+					a.msg(status.StatusFailed, "Could not compare versions of "+pkg, status.Error(err.Error()), nil)
+				}
+				return
+			}
+		}
+
 		switch {
 		case !ok:
 			unknown = append(unknown, pkg)
 			table.AppendRow(
 				status.TableCell{Status: status.StatusNone, Content: pkg},
 				status.TableCell{Status: status.StatusFailed, Content: "None"},
+				status.TableCell{Status: status.StatusNone, Content: requested},
 				status.TableCell{Status: status.StatusNone, Content: "Unknown"},
 			)
+		case info.Installed && (expected == "" || upToDate):
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: pkg},
+				status.TableCell{Status: status.StatusApplied, Content: info.Version},
+				status.TableCell{Status: status.StatusNone, Content: requested},
+				availableCell,
+			)
 		case info.Installed:
-			switch {
-			case info.AvailableVersion == "":
-				table.AppendRow(
-					status.TableCell{Status: status.StatusNone, Content: pkg},
-					status.TableCell{Status: status.StatusApplied, Content: info.Version},
-					status.TableCell{Status: status.StatusNone, Content: "None"},
-				)
-			case info.Version == info.AvailableVersion:
-				table.AppendRow(
-					status.TableCell{Status: status.StatusNone, Content: pkg},
-					status.TableCell{Status: status.StatusApplied, Content: info.Version},
-					status.TableCell{Status: status.StatusNone, Content: info.AvailableVersion},
-				)
-			default:
-				needToInstall = append(needToInstall, pkg)
-				table.AppendRow(
-					status.TableCell{Status: status.StatusNone, Content: pkg},
-					status.TableCell{Status: status.StatusTodo, Content: info.Version},
-					status.TableCell{Status: status.StatusNone, Content: info.AvailableVersion},
-				)
-			}
+			needToInstall = append(needToInstall, spec.String())
+			// Only needed if the requested version is older than the installed one
+			downgrade = downgrade || newerInstalled
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: pkg},
+				status.TableCell{Status: status.StatusTodo, Content: info.Version},
+				status.TableCell{Status: status.StatusNone, Content: requested},
+				availableCell,
+			)
 		default:
-			needToInstall = append(needToInstall, pkg)
+			needToInstall = append(needToInstall, spec.String())
 			table.AppendRow(
 				status.TableCell{Status: status.StatusNone, Content: pkg},
 				status.TableCell{Status: status.StatusTodo, Content: "None"},
-				status.TableCell{Status: status.StatusNone, Content: info.AvailableVersion},
+				status.TableCell{Status: status.StatusNone, Content: requested},
+				availableCell,
 			)
 		}
 	}
@@ -113,17 +297,22 @@ func (a *aptInstall) update(knownPackages map[string]external.DpkgPackage) {
 	var info string
 	if len(unknown) > 0 {
 		msgStatus = status.StatusFailed
-		info = strings.Join(needToInstall, ", ") + "unknown"
+		info = "Unknown packages " + strings.Join(unknown, ", ")
 	} else if len(needToInstall) > 0 {
 		msgStatus = status.StatusTodo
 		info = "Need to install " + strings.Join(needToInstall, ", ")
-	} else if len(pkgs) == 1 {
-		info = "Package " + pkgs[0] + " installed"
+	} else if len(specs) == 1 {
+		info = "Package " + specs[0].String() + " installed"
 	} else {
-		info = "Packages " + strings.Join(pkgs, ", ") + " installed"
+		names := make([]string, len(specs))
+		for i, spec := range specs {
+			names[i] = spec.String()
+		}
+		info = "Packages " + strings.Join(names, ", ") + " installed"
 	}
 
 	a.needToInstall = needToInstall
+	a.downgrade = downgrade
 	if !a.applying.Load() {
 		a.msg(msgStatus, info, &table, nil)
 	}
@@ -133,6 +322,8 @@ func (a *aptInstall) Stop() {
 	if a.close != nil {
 		a.close()
 	}
+
+	a.unregister()
 }
 
 func (a *aptInstall) Apply() bool {
@@ -141,6 +332,18 @@ func (a *aptInstall) Apply() bool {
 	a.applying.Store(true)
 	defer a.applying.Store(false)
 
+	args := []string{}
+	if a.noRecommends {
+		args = append(args, "--no-install-recommends")
+	}
+	if release := a.vars.Replace(a.release); release != "" {
+		args = append(args, "--target-release", release)
+	}
+	if a.downgrade {
+		// Requested versions may be older than the installed ones
+		args = append(args, "--allow-downgrades")
+	}
+
 	return external.AptGet(
 		func(s status.Status, info string, detail status.Detail) {
 			if info == "" {
@@ -156,6 +359,6 @@ func (a *aptInstall) Apply() bool {
 			}
 			a.msg(s, info, detail, nil)
 		},
-		"install", a.needToInstall...,
+		"install", append(args, a.needToInstall...)...,
 	)
 }
@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/assets"
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = register(
+	"apt install deb",
+	"packages",
+	"Install a .deb package file using apt",
+	ParamsDesc{
+		{"deb", "Package file (uploaded file or local path)", ParamTypeAssetOrPath},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &aptInstallDeb{
+			msg:  msg,
+			vars: vars,
+			deb:  params["deb"].(string),
+		}
+	},
+)
+
+type aptInstallDeb struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	deb string
+
+	applying atomic.Bool
+	close    func()
+}
+
+func (a *aptInstallDeb) UpdateVariables(vars variables.Variables) {
+	if a.vars.Update(vars) {
+		a.update(external.DpkgPackages())
+	}
+}
+
+func (a *aptInstallDeb) Watch() {
+	signals, close := external.DpkgListen()
+	a.close = close
+
+	go func() {
+		for knownPackages := range signals {
+			a.update(knownPackages)
+		}
+	}()
+}
+
+// debPath returns the path to the package file, which is either an asset or
+// a local file.
+func (a *aptInstallDeb) debPath() (string, error) {
+	deb := a.vars.Replace(a.deb)
+	if path, err := assets.Path(deb); err == nil {
+		return path, nil
+	}
+	if !filepath.IsAbs(deb) {
+		return "", fmt.Errorf("%q is neither an uploaded file nor an absolute path", deb)
+	}
+	return deb, nil
+}
+
+func (a *aptInstallDeb) update(knownPackages map[string]external.DpkgPackage) {
+	if a.applying.Load() {
+		return
+	}
+	a.msg(a.check(knownPackages))
+}
+
+func (a *aptInstallDeb) check(knownPackages map[string]external.DpkgPackage) (status.Status, string, status.Detail, variables.Variables) {
+	path, err := a.debPath()
+	if err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	name, version, err := external.DebInfo(path)
+	if err != nil {
+		return status.StatusFailed, "Could not read package file", status.Error(err.Error()), nil
+	}
+
+	table := status.Table{
+		Header: []string{"Package", "Installed Version", "File Version"},
+	}
+
+	info, ok := knownPackages[name]
+	installed := "None"
+	if ok && info.Installed {
+		installed = info.Version
+	}
+
+	if installed != "None" && installed != version {
+		newer, err := external.DpkgCompareVersions(installed, "gt", version)
+		if err != nil {
+			return status.StatusFailed, "Could not compare versions of " + name, status.Error(err.Error()), nil
+		}
+		if newer {
+			// Installing the file would be a downgrade
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: name},
+				status.TableCell{Status: status.StatusFailed, Content: installed},
+				status.TableCell{Status: status.StatusNone, Content: version},
+			)
+			return status.StatusFailed, fmt.Sprintf("Newer version %s of %s is installed, not downgrading to %s", installed, name, version), &table, nil
+		}
+	}
+
+	if installed != version {
+		table.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: name},
+			status.TableCell{Status: status.StatusTodo, Content: installed},
+			status.TableCell{Status: status.StatusNone, Content: version},
+		)
+		return status.StatusTodo, fmt.Sprintf("Need to install %s %s", name, version), &table, nil
+	}
+
+	table.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: name},
+		status.TableCell{Status: status.StatusApplied, Content: installed},
+		status.TableCell{Status: status.StatusNone, Content: version},
+	)
+	return status.StatusApplied, fmt.Sprintf("Package %s %s installed", name, version), &table, nil
+}
+
+func (a *aptInstallDeb) Stop() {
+	if a.close != nil {
+		a.close()
+	}
+}
+
+func (a *aptInstallDeb) Apply() bool {
+	a.applying.Store(true)
+	defer a.applying.Store(false)
+
+	// Never install over a newer version
+	if checkStatus, info, detail, _ := a.check(external.DpkgPackages()); checkStatus == status.StatusFailed {
+		a.msg(checkStatus, info, detail, nil)
+		return false
+	}
+
+	path, err := a.debPath()
+	if err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	name, version, err := external.DebInfo(path)
+	if err != nil {
+		a.msg(status.StatusFailed, "Could not read package file", status.Error(err.Error()), nil)
+		return false
+	}
+
+	// apt-get only recognizes package files by their extension
+	if !strings.HasSuffix(path, ".deb") {
+		tmpPath, err := copyToTempDeb(path)
+		if err != nil {
+			a.msg(status.StatusFailed, "Could not prepare package file", status.Error(err.Error()), nil)
+			return false
+		}
+		defer os.Remove(tmpPath)
+		path = tmpPath
+	}
+
+	pkgMsg := name + " " + version
+
+	return external.AptGet(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = "Installing " + pkgMsg
+				case status.StatusApplied:
+					info = "Successfully installed " + pkgMsg
+				case status.StatusFailed:
+					info = "Failed installing " + pkgMsg
+				}
+			}
+			a.msg(s, info, detail, nil)
+		},
+		"install", path,
+	)
+}
+
+// copyToTempDeb copies a package file to a temporary file with the .deb
+// extension.
+func copyToTempDeb(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp("", "keepakonf-*.deb")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// apt downloads as the _apt user, which must be able to read the file
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
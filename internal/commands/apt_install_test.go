@@ -0,0 +1,52 @@
+package commands
+
+import "testing"
+
+func TestParseAptPackageSpec(t *testing.T) {
+	for _, tc := range []struct {
+		spec     string
+		expected aptPackageSpec
+		key      string
+	}{
+		{"vim", aptPackageSpec{name: "vim"}, "vim"},
+		{"libfoo:i386", aptPackageSpec{name: "libfoo", arch: "i386"}, "libfoo:i386"},
+		{"vim=2:9.0.1378-2", aptPackageSpec{name: "vim", version: "2:9.0.1378-2"}, "vim"},
+		{"libfoo:i386=1:2.0-1", aptPackageSpec{name: "libfoo", arch: "i386", version: "1:2.0-1"}, "libfoo:i386"},
+	} {
+		spec := parseAptPackageSpec(tc.spec)
+		if spec != tc.expected {
+			t.Errorf("parseAptPackageSpec(%q) = %+v, expected %+v", tc.spec, spec, tc.expected)
+		}
+		if spec.key() != tc.key {
+			t.Errorf("key of %q = %q, expected %q", tc.spec, spec.key(), tc.key)
+		}
+		if spec.String() != tc.spec {
+			t.Errorf("String() of %q = %q", tc.spec, spec.String())
+		}
+	}
+}
+
+func TestAptPackagesRequestedByOtherGroups(t *testing.T) {
+	first := &aptInstall{group: "first", packages: []string{"vim", "libfoo:i386=1.0-1", "vim"}}
+	second := &aptInstall{group: "second", packages: []string{"curl"}}
+
+	// Registered as soon as watching, before any package list is received
+	first.register()
+	second.register()
+	defer second.unregister()
+
+	requested := aptPackagesRequestedByOtherGroups("second")
+	if len(requested) != 2 {
+		t.Errorf("unexpected requested packages %v", requested)
+	}
+	for _, pkg := range []string{"vim", "libfoo:i386"} {
+		if _, ok := requested[pkg]; !ok {
+			t.Errorf("%q should be requested by another group", pkg)
+		}
+	}
+
+	first.unregister()
+	if requested := aptPackagesRequestedByOtherGroups("second"); len(requested) != 0 {
+		t.Errorf("unexpected requested packages %v after stopping", requested)
+	}
+}
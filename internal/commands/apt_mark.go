@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = register(
+	"apt mark",
+	"packages",
+	"Mark packages as manually or automatically installed",
+	ParamsDesc{
+		{"packages", "Packages to mark", ParamTypeStringArray},
+		{"auto", "Mark as automatically installed", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &aptMark{
+			msg:      msg,
+			vars:     vars,
+			packages: params["packages"].([]string),
+			auto:     params["auto"].(bool),
+		}
+	},
+)
+
+type aptMark struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	packages []string
+	auto     bool
+
+	needToMark []string
+
+	applying atomic.Bool
+	close    []func()
+}
+
+func (a *aptMark) UpdateVariables(vars variables.Variables) {
+	if a.vars.Update(vars) {
+		a.update(external.AptAutoPackages())
+	}
+}
+
+func (a *aptMark) Watch() {
+	autoSignals, autoClose := external.AptAutoListen()
+	// Installing or removing packages changes which ones can be marked
+	dpkgSignals, dpkgClose := external.DpkgListen()
+	a.close = []func(){autoClose, dpkgClose}
+
+	go func() {
+		for {
+			select {
+			case autoPackages, ok := <-autoSignals:
+				if !ok {
+					return
+				}
+				a.update(autoPackages)
+			case _, ok := <-dpkgSignals:
+				if !ok {
+					return
+				}
+				a.update(external.AptAutoPackages())
+			}
+		}
+	}()
+}
+
+// mark returns the apt-mark command and the expected mark description.
+func (a *aptMark) mark() (cmd, description string) {
+	if a.auto {
+		return "auto", "Automatic"
+	}
+	return "manual", "Manual"
+}
+
+func (a *aptMark) update(autoPackages map[string]bool) {
+	needToMark := []string{}
+	notInstalled := []string{}
+
+	knownPackages := external.DpkgPackages()
+	_, expected := a.mark()
+
+	msgStatus := status.StatusApplied
+	table := status.Table{
+		Header: []string{"Package", "Installed version", "Mark"},
+	}
+	pkgs := a.vars.ReplaceSlice(a.packages)
+
+	for _, pkg := range pkgs {
+		if pkg == "" {
+			continue
+		}
+		info, ok := knownPackages[pkg]
+		if !ok || !info.Installed {
+			notInstalled = append(notInstalled, pkg)
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: pkg},
+				status.TableCell{Status: status.StatusFailed, Content: "None"},
+				status.TableCell{Status: status.StatusNone, Content: ""},
+			)
+			continue
+		}
+
+		current := "Manual"
+		if autoPackages[pkg] {
+			current = "Automatic"
+		}
+
+		markStatus := status.StatusApplied
+		if current != expected {
+			markStatus = status.StatusTodo
+			needToMark = append(needToMark, pkg)
+		}
+		table.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: pkg},
+			status.TableCell{Status: status.StatusNone, Content: info.Version},
+			status.TableCell{Status: markStatus, Content: current},
+		)
+	}
+
+	var info string
+	if len(notInstalled) > 0 {
+		msgStatus = status.StatusFailed
+		info = "Packages not installed " + strings.Join(notInstalled, ", ")
+	} else if len(needToMark) > 0 {
+		msgStatus = status.StatusTodo
+		info = "Need to mark " + strings.Join(needToMark, ", ") + " as " + strings.ToLower(expected)
+	} else if len(pkgs) == 1 {
+		info = "Package " + pkgs[0] + " marked as " + strings.ToLower(expected)
+	} else {
+		info = "Packages " + strings.Join(pkgs, ", ") + " marked as " + strings.ToLower(expected)
+	}
+
+	a.needToMark = needToMark
+	if !a.applying.Load() {
+		a.msg(msgStatus, info, &table, nil)
+	}
+}
+
+func (a *aptMark) Stop() {
+	for _, close := range a.close {
+		close()
+	}
+	a.close = nil
+}
+
+func (a *aptMark) Apply() bool {
+	if len(a.needToMark) == 0 {
+		// Nothing to mark while not applied: some packages are not installed
+		return false
+	}
+
+	cmd, expected := a.mark()
+	needToMarkMsg := strings.Join(a.needToMark, ", ") + " as " + strings.ToLower(expected)
+
+	a.applying.Store(true)
+	defer a.applying.Store(false)
+
+	return external.AptMark(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = "Marking " + needToMarkMsg
+				case status.StatusApplied:
+					info = "Successfully marked " + needToMarkMsg
+				case status.StatusFailed:
+					info = "Failed marking " + needToMarkMsg
+				}
+			}
+			a.msg(s, info, detail, nil)
+		},
+		cmd, a.needToMark...,
+	)
+}
@@ -0,0 +1,156 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+// The file must sort after the 10periodic and 20auto-upgrades files installed
+// by packages, so that its settings take precedence.
+const aptPeriodicPath = "/etc/apt/apt.conf.d/99keepakonf-periodic"
+
+// Intervals are in days, or may have a s, m, h or d suffix.
+var aptPeriodicIntervalRe = regexp.MustCompile(`^[0-9]+[smhd]?$`)
+
+// aptPeriodicIntervals are the APT::Periodic settings, by parameter.
+var aptPeriodicIntervals = []struct {
+	param   string
+	title   string
+	setting string
+}{
+	{"update", "Update package lists interval", "Update-Package-Lists"},
+	{"download", "Download upgradeable packages interval", "Download-Upgradeable-Packages"},
+	{"autoclean", "Autoclean interval", "AutocleanInterval"},
+	{"unattended", "Unattended upgrade interval", "Unattended-Upgrade"},
+}
+
+var _ = registerFileWatcher(
+	"apt periodic",
+	"ubuntu",
+	"Configure periodic apt update, upgrade, autoclean and unattended upgrades",
+	func() ParamsDesc {
+		params := ParamsDesc{}
+		for _, interval := range aptPeriodicIntervals {
+			params = append(params, ParamDesc{interval.param, interval.title + " (days, empty for default)", ParamTypeOptString})
+		}
+		return append(
+			params,
+			ParamDesc{"origins", "Unattended upgrade origins patterns", ParamTypeStringArray},
+			ParamDesc{"reboot", "Automatically reboot after unattended upgrades if required", ParamTypeBool},
+			ParamDesc{"reboottime", "Automatic reboot time", ParamTypeOptString},
+		)
+	}(),
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		intervals := map[string]string{}
+		for _, interval := range aptPeriodicIntervals {
+			intervals[interval.setting] = params[interval.param].(string)
+		}
+		return &aptPeriodic{
+			fileContent: fileContent{
+				fileWatcherCmdInit(map[string]any{"path": aptPeriodicPath}, vars, msg),
+				"",
+				"root",
+			},
+			intervals:  intervals,
+			origins:    params["origins"].([]string),
+			reboot:     params["reboot"].(bool),
+			rebootTime: params["reboottime"].(string),
+		}
+	},
+)
+
+// "apt no updates" disabled update, download and autoclean in 10periodic. The
+// migrated instruction writes the same settings to its own file, which takes
+// precedence over 10periodic, so the old file can stay as it is.
+var _ = registerMigration(
+	"apt no updates",
+	func(map[string]any) (string, map[string]any) {
+		return "apt periodic", map[string]any{
+			"update":    "0",
+			"download":  "0",
+			"autoclean": "0",
+		}
+	},
+)
+
+// aptPeriodic is a fileContent whose content is generated from the
+// parameters.
+type aptPeriodic struct {
+	fileContent
+
+	intervals  map[string]string
+	origins    []string
+	reboot     bool
+	rebootTime string
+}
+
+func (a *aptPeriodic) render() (string, error) {
+	var content strings.Builder
+	content.WriteString("# Placed by Keepakonf\n")
+
+	for _, interval := range aptPeriodicIntervals {
+		value := a.vars.Replace(a.intervals[interval.setting])
+		if value == "" {
+			continue
+		}
+		if !aptPeriodicIntervalRe.MatchString(value) {
+			return "", fmt.Errorf("%q is not a valid interval for %s", value, interval.setting)
+		}
+		fmt.Fprintf(&content, "APT::Periodic::%s %q;\n", interval.setting, value)
+	}
+
+	origins := []string{}
+	for _, origin := range a.vars.ReplaceSlice(a.origins) {
+		if origin == "" {
+			continue
+		}
+		if strings.Contains(origin, `"`) {
+			return "", fmt.Errorf("origin pattern %q must not contain double quotes", origin)
+		}
+		origins = append(origins, origin)
+	}
+	if len(origins) > 0 {
+		// Replace the patterns from other files instead of adding to them
+		content.WriteString("#clear Unattended-Upgrade::Origins-Pattern;\n")
+		content.WriteString("Unattended-Upgrade::Origins-Pattern {\n")
+		for _, origin := range origins {
+			content.WriteString("\t\"" + origin + "\";\n")
+		}
+		content.WriteString("};\n")
+	}
+
+	if a.reboot {
+		content.WriteString("Unattended-Upgrade::Automatic-Reboot \"true\";\n")
+	}
+	if rebootTime := a.vars.Replace(a.rebootTime); rebootTime != "" {
+		if strings.Contains(rebootTime, `"`) {
+			return "", fmt.Errorf("reboot time %q must not contain double quotes", rebootTime)
+		}
+		content.WriteString("Unattended-Upgrade::Automatic-Reboot-Time \"" + rebootTime + "\";\n")
+	}
+
+	return content.String(), nil
+}
+
+func (a *aptPeriodic) newStatus(fstatus external.FileStatus) {
+	content, err := a.render()
+	if err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return
+	}
+	a.checkContent(fstatus, content)
+}
+
+func (a *aptPeriodic) apply() bool {
+	content, err := a.render()
+	if err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+	return a.writeContent(content)
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const aptPreferencesDir = "/etc/apt/preferences.d"
+
+var _ = registerFileWatcher(
+	"apt pin",
+	"pin",
+	"Pin packages to a version or a release",
+	ParamsDesc{
+		{"name", "Preferences file name", ParamTypeString},
+		{"packages", "Packages", ParamTypeStringArray},
+		{"pin", "Pin (eg. version 1.2.*, release a=bookworm-backports)", ParamTypeString},
+		{"priority", "Pin priority", ParamTypeString},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &aptPin{
+			fileContent: fileContent{
+				fileWatcherCmd: fileWatcherCmdInit(
+					map[string]any{"path": filepath.Join(aptPreferencesDir, params["name"].(string))},
+					vars, msg,
+				),
+				owner: "root",
+			},
+			name:     params["name"].(string),
+			packages: params["packages"].([]string),
+			pin:      params["pin"].(string),
+			priority: params["priority"].(string),
+		}
+	},
+)
+
+type aptPin struct {
+	fileContent
+
+	name     string
+	packages []string
+	pin      string
+	priority string
+}
+
+func (a *aptPin) wantedPackages() []string {
+	packages := []string{}
+	for _, pkg := range a.vars.ReplaceSlice(a.packages) {
+		if pkg = strings.TrimSpace(pkg); pkg != "" {
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
+
+// validate returns an error if the parameters would not make a valid apt
+// preferences file.
+func (a *aptPin) validate() error {
+	name := a.vars.Replace(a.name)
+	switch {
+	case name == "":
+		return errors.New("preferences file name must not be empty")
+	case strings.Contains(name, "/"):
+		return fmt.Errorf("preferences file name %q must not contain \"/\"", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("preferences file name %q must not start with \".\"", name)
+	}
+	if len(a.wantedPackages()) == 0 {
+		return errors.New("at least one package is needed")
+	}
+	if strings.TrimSpace(a.vars.Replace(a.pin)) == "" {
+		return errors.New("pin must not be empty")
+	}
+	priority := a.vars.Replace(a.priority)
+	if priority == "" {
+		return errors.New("pin priority must not be empty")
+	}
+	if _, err := strconv.Atoi(priority); err != nil {
+		return fmt.Errorf("pin priority %q must be an integer", priority)
+	}
+	return nil
+}
+
+func (a *aptPin) content() string {
+	return "# Placed by Keepakonf\n" +
+		"Package: " + strings.Join(a.wantedPackages(), " ") + "\n" +
+		"Pin: " + strings.TrimSpace(a.vars.Replace(a.pin)) + "\n" +
+		"Pin-Priority: " + a.vars.Replace(a.priority) + "\n"
+}
+
+func (a *aptPin) newStatus(fstatus external.FileStatus) {
+	if err := a.validate(); err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return
+	}
+	a.checkContent(fstatus, a.content())
+}
+
+func (a *aptPin) apply() bool {
+	if err := a.validate(); err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+	if !a.writeContent(a.content()) {
+		return false
+	}
+	// Candidate versions depend on the preferences
+	go external.AptCacheRefresh()
+	return true
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 	"sync/atomic"
 
@@ -34,8 +36,9 @@ var _ = register(
 )
 
 type aptRemove struct {
-	msg  status.SendStatus
-	vars variables.Variables
+	msg   status.SendStatus
+	vars  variables.Variables
+	group string
 
 	packages []string
 	cmd      string
@@ -46,6 +49,10 @@ type aptRemove struct {
 	close    func()
 }
 
+func (a *aptRemove) setGroup(id string) {
+	a.group = id
+}
+
 func (a *aptRemove) UpdateVariables(vars variables.Variables) {
 	if a.vars.Update(vars) {
 		a.update(external.DpkgPackages())
@@ -69,7 +76,7 @@ func (a *aptRemove) update(knownPackages map[string]external.DpkgPackage) {
 
 	msgStatus := status.StatusApplied
 	table := status.Table{
-		Header: []string{"Package", "Installed version"},
+		Header: []string{"Package", "Installed version", "Reason"},
 	}
 	pkgs := a.vars.ReplaceSlice(a.packages)
 
@@ -83,17 +90,20 @@ func (a *aptRemove) update(knownPackages map[string]external.DpkgPackage) {
 			table.AppendRow(
 				status.TableCell{Status: status.StatusNone, Content: pkg},
 				status.TableCell{Status: status.StatusApplied, Content: "None"},
+				status.TableCell{Status: status.StatusNone, Content: "Requested"},
 			)
 		case info.Installed:
 			needToRemove = append(needToRemove, pkg)
 			table.AppendRow(
 				status.TableCell{Status: status.StatusNone, Content: pkg},
 				status.TableCell{Status: status.StatusTodo, Content: info.Version},
+				status.TableCell{Status: status.StatusNone, Content: "Requested"},
 			)
 		default:
 			table.AppendRow(
 				status.TableCell{Status: status.StatusNone, Content: pkg},
 				status.TableCell{Status: status.StatusApplied, Content: "None"},
+				status.TableCell{Status: status.StatusNone, Content: "Requested"},
 			)
 		}
 	}
@@ -102,6 +112,44 @@ func (a *aptRemove) update(knownPackages map[string]external.DpkgPackage) {
 	if len(needToRemove) > 0 {
 		msgStatus = status.StatusTodo
 		info = "Need to remove " + strings.Join(needToRemove, ", ")
+
+		// Show the other packages apt would remove along with the requested ones
+		removals, err := external.AptSimulateRemovals(a.cmd, needToRemove...)
+		if err != nil {
+			a.needToRemove = needToRemove
+			if !a.applying.Load() {
+				a.msg(status.StatusFailed, "Could not simulate removal of "+strings.Join(needToRemove, ", "), status.Error(err.Error()), nil)
+			}
+			return
+		}
+		conflicts := a.conflicts(removals, knownPackages)
+		for i, row := range table.Rows {
+			if pkg := row[0].Content; slices.Contains(conflicts, pkg) {
+				table.Rows[i][2] = status.TableCell{Status: status.StatusFailed, Content: "Requested, installed by another group"}
+			}
+		}
+		var dependencies int
+		for _, removal := range removals {
+			if slices.Contains(needToRemove, removal.Name) {
+				continue
+			}
+			dependencies++
+			reason := status.TableCell{Status: status.StatusNone, Content: "Dependency"}
+			if slices.Contains(conflicts, removal.Name) {
+				reason = status.TableCell{Status: status.StatusFailed, Content: "Dependency, installed by another group"}
+			}
+			table.AppendRow(
+				status.TableCell{Status: status.StatusNone, Content: removal.Name},
+				status.TableCell{Status: status.StatusTodo, Content: removal.Version},
+				reason,
+			)
+		}
+		if dependencies > 0 {
+			info += fmt.Sprintf(" and %d dependent packages", dependencies)
+		}
+		if len(conflicts) > 0 {
+			info += ", but " + strings.Join(conflicts, ", ") + " installed by another group"
+		}
 	} else if len(pkgs) == 1 {
 		info = "Package " + pkgs[0] + " removed"
 	} else {
@@ -114,6 +162,35 @@ func (a *aptRemove) update(knownPackages map[string]external.DpkgPackage) {
 	}
 }
 
+// conflicts returns the packages among removals which apt install
+// instructions in other groups want installed.
+func (a *aptRemove) conflicts(removals []external.AptRemoval, knownPackages map[string]external.DpkgPackage) []string {
+	requested := map[string]struct{}{}
+	for pkg := range aptPackagesRequestedByOtherGroups(a.group) {
+		requested[aptQualifiedName(pkg, knownPackages)] = struct{}{}
+	}
+
+	conflicts := []string{}
+	for _, removal := range removals {
+		if _, ok := requested[aptQualifiedName(removal.Name, knownPackages)]; ok {
+			conflicts = append(conflicts, removal.Name)
+		}
+	}
+	return conflicts
+}
+
+// aptQualifiedName returns the package name qualified with the architecture of
+// the installed package, as apt omits the native architecture.
+func aptQualifiedName(name string, knownPackages map[string]external.DpkgPackage) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	if info, ok := knownPackages[name]; ok && info.Architecture != "" {
+		return name + ":" + info.Architecture
+	}
+	return name
+}
+
 func (a *aptRemove) Stop() {
 	if a.close != nil {
 		a.close()
@@ -126,6 +203,22 @@ func (a *aptRemove) Apply() bool {
 	a.applying.Store(true)
 	defer a.applying.Store(false)
 
+	removals, err := external.AptSimulateRemovals(a.cmd, a.needToRemove...)
+	if err != nil {
+		a.msg(status.StatusFailed, "Could not simulate removal of "+needToRemoveMsg, status.Error(err.Error()), nil)
+		return false
+	}
+
+	// Never remove packages other groups want installed
+	if conflicts := a.conflicts(removals, external.DpkgPackages()); len(conflicts) > 0 {
+		a.msg(
+			status.StatusFailed,
+			"Refusing to remove "+needToRemoveMsg+", it would remove "+strings.Join(conflicts, ", ")+", installed by another group",
+			nil, nil,
+		)
+		return false
+	}
+
 	return external.AptGet(
 		func(s status.Status, info string, detail status.Detail) {
 			if info == "" {
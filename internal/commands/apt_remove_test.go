@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/willoma/keepakonf/internal/external"
+)
+
+func TestAptQualifiedName(t *testing.T) {
+	// Packages are known by name and by name qualified with their architecture
+	known := map[string]external.DpkgPackage{
+		"libfoo":        {Name: "libfoo", Architecture: "amd64", Installed: true},
+		"libfoo:amd64":  {Name: "libfoo", Architecture: "amd64", Installed: true},
+		"libfoo:i386":   {Name: "libfoo", Architecture: "i386", Installed: true},
+		"fonts-bar":     {Name: "fonts-bar", Architecture: "all", Installed: true},
+		"fonts-bar:all": {Name: "fonts-bar", Architecture: "all", Installed: true},
+		"legacy":        {Name: "legacy"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		{"libfoo", "libfoo:amd64"},
+		{"libfoo:amd64", "libfoo:amd64"},
+		{"libfoo:i386", "libfoo:i386"},
+		{"fonts-bar", "fonts-bar:all"},
+		{"legacy", "legacy"},
+		{"unknown", "unknown"},
+	} {
+		if qualified := aptQualifiedName(tc.name, known); qualified != tc.expected {
+			t.Errorf("aptQualifiedName(%q) = %q, expected %q", tc.name, qualified, tc.expected)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"slices"
+	"strings"
 	"sync/atomic"
 
 	"github.com/willoma/keepakonf/internal/external"
@@ -12,11 +14,20 @@ var _ = register(
 	"apt upgrade",
 	"packages",
 	"Upgrade packages from APT repositories",
-	ParamsDesc{},
+	ParamsDesc{
+		{"security", "Only security upgrades", ParamTypeBool},
+		{"exclude", "Packages not to upgrade", ParamTypeStringArray},
+		{"safe", "Never remove packages (upgrade instead of full-upgrade)", ParamTypeBool},
+		{"phased", "Install phased updates before their rollout", ParamTypeBool},
+	},
 	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
 		return &aptUpgrade{
-			msg:  msg,
-			vars: vars,
+			msg:      msg,
+			vars:     vars,
+			security: params["security"].(bool),
+			exclude:  params["exclude"].([]string),
+			safe:     params["safe"].(bool),
+			phased:   params["phased"].(bool),
 		}
 	},
 )
@@ -25,12 +36,21 @@ type aptUpgrade struct {
 	msg  status.SendStatus
 	vars variables.Variables
 
+	security bool
+	exclude  []string
+	safe     bool
+	phased   bool
+
+	needToUpgrade []string
+
 	applying atomic.Bool
 	close    func()
 }
 
 func (a *aptUpgrade) UpdateVariables(vars variables.Variables) {
-	a.vars.Update(vars)
+	if a.vars.Update(vars) {
+		a.update(external.AptCachePackagesList())
+	}
 }
 
 func (a *aptUpgrade) Watch() {
@@ -39,37 +59,71 @@ func (a *aptUpgrade) Watch() {
 
 	go func() {
 		for packages := range aptcache {
-			if a.applying.Load() {
-				// No update if it is currently applying
-				continue
-			}
+			a.update(packages)
+		}
+	}()
+}
 
-			table := status.Table{
-				Header: []string{"Package", "Installed version", "Available version"},
-			}
+// isSecurityUpgrade returns true if the available version of the package comes
+// from a security archive.
+func isSecurityUpgrade(pkg external.DpkgPackage) bool {
+	for _, origin := range pkg.Origins {
+		if strings.HasSuffix(origin, "-security") {
+			return true
+		}
+	}
+	return false
+}
 
-			var needToUpgrade bool
+func (a *aptUpgrade) update(packages []external.DpkgPackage) {
+	if a.applying.Load() {
+		// No update if it is currently applying
+		return
+	}
 
-			for _, pkg := range packages {
-				if !pkg.Installed || pkg.Version == pkg.AvailableVersion || pkg.AvailableVersion == "" {
-					continue
-				}
+	table := status.Table{
+		Header: []string{"Package", "Installed version", "Available version", "Origin"},
+	}
 
-				table.AppendRow(
-					status.TableCell{Status: status.StatusNone, Content: pkg.Name},
-					status.TableCell{Status: status.StatusNone, Content: pkg.Version},
-					status.TableCell{Status: status.StatusNone, Content: pkg.AvailableVersion},
-				)
-				needToUpgrade = true
-			}
+	needToUpgrade := []string{}
+	exclude := a.excluded()
 
-			if needToUpgrade {
-				a.msg(status.StatusTodo, "Need to upgrade packages", &table, nil)
-			} else {
-				a.msg(status.StatusApplied, "All packages up-to-date", nil, nil)
-			}
+	for _, pkg := range packages {
+		if !pkg.Installed || pkg.Held() || pkg.Version == pkg.AvailableVersion || pkg.AvailableVersion == "" {
+			continue
 		}
-	}()
+		if slices.Contains(exclude, pkg.Name) || (a.security && !isSecurityUpgrade(pkg)) {
+			continue
+		}
+
+		origin := strings.Join(pkg.Origins, ", ")
+		rowStatus := status.StatusTodo
+		if pkg.Phased && !a.phased {
+			// apt decides by itself when to install phased updates
+			origin += " (phased)"
+			rowStatus = status.StatusNone
+		} else {
+			needToUpgrade = append(needToUpgrade, pkg.Name)
+		}
+
+		table.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: pkg.Name},
+			status.TableCell{Status: rowStatus, Content: pkg.Version},
+			status.TableCell{Status: status.StatusNone, Content: pkg.AvailableVersion},
+			status.TableCell{Status: status.StatusNone, Content: origin},
+		)
+	}
+
+	a.needToUpgrade = needToUpgrade
+
+	switch {
+	case len(needToUpgrade) > 0:
+		a.msg(status.StatusTodo, "Need to upgrade packages", &table, nil)
+	case len(table.Rows) > 0:
+		a.msg(status.StatusApplied, "All packages up-to-date, except phased updates", &table, nil)
+	default:
+		a.msg(status.StatusApplied, "All packages up-to-date", nil, nil)
+	}
 }
 
 func (a *aptUpgrade) Stop() {
@@ -78,10 +132,40 @@ func (a *aptUpgrade) Stop() {
 	}
 }
 
+// excluded returns the packages not to upgrade, ignoring empty entries.
+func (a *aptUpgrade) excluded() []string {
+	exclude := []string{}
+	for _, pkg := range a.vars.ReplaceSlice(a.exclude) {
+		if pkg = strings.TrimSpace(pkg); pkg != "" {
+			exclude = append(exclude, pkg)
+		}
+	}
+	return exclude
+}
+
 func (a *aptUpgrade) Apply() bool {
 	a.applying.Store(true)
 	defer a.applying.Store(false)
 
+	args := []string{}
+	if a.phased {
+		args = append(args, "-o", "APT::Get::Always-Include-Phased-Updates=true")
+	}
+
+	cmd := "dist-upgrade"
+	switch {
+	case a.security || len(a.excluded()) > 0:
+		// Only the selected packages must be upgraded
+		cmd = "install"
+		args = append(args, "--only-upgrade")
+		if a.safe {
+			args = append(args, "--no-remove")
+		}
+		args = append(args, a.needToUpgrade...)
+	case a.safe:
+		cmd = "upgrade"
+	}
+
 	return external.AptGet(
 		func(s status.Status, info string, detail status.Detail) {
 			if info == "" {
@@ -97,6 +181,6 @@ func (a *aptUpgrade) Apply() bool {
 			}
 			a.msg(s, info, detail, nil)
 		},
-		"dist-upgrade",
+		cmd, args...,
 	)
 }
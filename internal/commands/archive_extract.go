@@ -0,0 +1,669 @@
+package commands
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+// Manifests of extracted archives are stored in this directory, one file per
+// destination directory.
+const archiveManifestsDir = "/var/lib/keepakonf/archives"
+
+var _ = registerFileWatcher(
+	"archive extract",
+	"archive",
+	"Extract a .tar, .tar.gz, .tar.bz2 or .zip archive",
+	ParamsDesc{
+		{"archive", "Archive path or URL", ParamTypeString},
+		{"sha256", "Expected archive SHA-256 (empty to not verify)", ParamTypeOptString},
+		{"destination", "Destination directory", ParamTypeFilePath},
+		{"strip", "Path components to strip", ParamTypeString},
+		{"owner", "Files owner", ParamTypeUsername},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &archiveExtract{
+			msg:         msg,
+			vars:        vars,
+			archive:     params["archive"].(string),
+			sha256:      params["sha256"].(string),
+			destination: params["destination"].(string),
+			strip:       params["strip"].(string),
+			owner:       params["owner"].(string),
+		}
+	},
+)
+
+type archiveManifest struct {
+	Archive string `json:"archive"`
+	// Sum is the SHA-256 sum of the archive content
+	Sum   string `json:"sum"`
+	Strip int    `json:"strip"`
+	// Files associates each extracted file to its SHA-256 sum, or to "->"
+	// followed by its target for symbolic links.
+	Files map[string]string `json:"files"`
+}
+
+type archiveExtract struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	archive     string
+	sha256      string
+	destination string
+	strip       string
+	owner       string
+}
+
+func (a *archiveExtract) updateVariables(vars variables.Variables) (changed bool) {
+	return a.vars.Update(vars)
+}
+
+func (a *archiveExtract) getPath() string {
+	return a.vars.Replace(a.destination)
+}
+
+func (a *archiveExtract) manifestPath() string {
+	sum := sha256.Sum256([]byte(a.getPath()))
+	return filepath.Join(archiveManifestsDir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (a *archiveExtract) getStrip() (int, error) {
+	strip := a.vars.Replace(a.strip)
+	if strip == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(strip)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of path components to strip %q", strip)
+	}
+	return n, nil
+}
+
+func isArchiveURL(archive string) bool {
+	return strings.HasPrefix(archive, "http://") || strings.HasPrefix(archive, "https://")
+}
+
+// expectedSum returns the expected SHA-256 sum of the archive: the sha256
+// parameter if set, else the sum of local archives, else "" for URLs.
+func (a *archiveExtract) expectedSum() (string, error) {
+	sum := strings.ToLower(strings.TrimSpace(a.vars.Replace(a.sha256)))
+	if sum != "" {
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+			return "", fmt.Errorf("SHA-256 %q must be %d hexadecimal characters", sum, sha256.Size*2)
+		}
+		return sum, nil
+	}
+	archive := a.vars.Replace(a.archive)
+	if isArchiveURL(archive) {
+		return "", nil
+	}
+	return sha256File(archive)
+}
+
+// readManifest returns the manifest of the previous extraction.
+func (a *archiveExtract) readManifest() (archiveManifest, error) {
+	var manifest archiveManifest
+	manifestB, err := os.ReadFile(a.manifestPath())
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(manifestB, &manifest)
+	return manifest, err
+}
+
+func (a *archiveExtract) newStatus(fstatus external.FileStatus) {
+	switch fstatus {
+	case external.FileStatusDirectory:
+		a.msg(a.check())
+	case external.FileStatusFile:
+		a.msg(status.StatusFailed, fmt.Sprintf("%q is not a directory", a.getPath()), nil, nil)
+	case external.FileStatusUnknown:
+		a.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", a.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		a.msg(status.StatusTodo, fmt.Sprintf("Need to extract %q into %q", a.vars.Replace(a.archive), a.getPath()), nil, nil)
+	}
+}
+
+func (a *archiveExtract) check() (status.Status, string, status.Detail, variables.Variables) {
+	archive := a.vars.Replace(a.archive)
+	dest := a.getPath()
+
+	strip, err := a.getStrip()
+	if err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	sum, err := a.expectedSum()
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not read %q", archive), status.Error(err.Error()), nil
+	}
+
+	manifest, err := a.readManifest()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return status.StatusTodo, fmt.Sprintf("Need to extract %q into %q", archive, dest), nil, nil
+		}
+		return status.StatusTodo, fmt.Sprintf("Need to extract %q into %q", archive, dest), status.Error(err.Error()), nil
+	}
+
+	if manifest.Archive != archive || manifest.Strip != strip {
+		return status.StatusTodo, fmt.Sprintf("Need to extract %q into %q, previously extracted from %q", archive, dest, manifest.Archive), nil, nil
+	}
+	if sum != "" && manifest.Sum != sum {
+		return status.StatusTodo, fmt.Sprintf("Need to extract %q into %q, archive content changed", archive, dest), nil, nil
+	}
+
+	names := make([]string, 0, len(manifest.Files))
+	for name := range manifest.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := status.Table{
+		Header: []string{"File", "Status"},
+	}
+
+	for _, name := range names {
+		expected := manifest.Files[name]
+		fullPath := filepath.Join(dest, name)
+
+		var current string
+		if err = checkNoSymlinkParent(dest, name); err == nil {
+			if target, isLink := strings.CutPrefix(expected, "->"); isLink {
+				current, err = os.Readlink(fullPath)
+				if err == nil && current == target {
+					continue
+				}
+			} else {
+				current, err = sha256File(fullPath)
+				if err == nil && current == expected {
+					continue
+				}
+			}
+		}
+
+		fileStatus := "Modified"
+		if errors.Is(err, fs.ErrNotExist) {
+			fileStatus = "Missing"
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: name},
+			status.TableCell{Status: status.StatusTodo, Content: fileStatus},
+		)
+	}
+
+	if len(result.Rows) > 0 {
+		return status.StatusTodo, fmt.Sprintf("Need to extract %q into %q, %d files changed", archive, dest, len(result.Rows)), &result, nil
+	}
+	return status.StatusApplied, fmt.Sprintf("%d files from %q extracted into %q", len(names), archive, dest), nil, nil
+}
+
+func (a *archiveExtract) apply() bool {
+	archive := a.vars.Replace(a.archive)
+	dest := a.getPath()
+
+	strip, err := a.getStrip()
+	if err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	format, err := archiveFormat(archive)
+	if err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not extract %q", archive), status.Error(err.Error()), nil)
+		return false
+	}
+
+	localPath := archive
+	if isArchiveURL(archive) {
+		var ok bool
+		if localPath, ok = a.download(archive, format); !ok {
+			return false
+		}
+		defer os.Remove(localPath)
+	}
+
+	sum, err := sha256File(localPath)
+	if err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", archive), status.Error(err.Error()), nil)
+		return false
+	}
+	expectedSum, err := a.expectedSum()
+	if err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", archive), status.Error(err.Error()), nil)
+		return false
+	}
+	if expectedSum != "" && sum != expectedSum {
+		a.msg(
+			status.StatusFailed,
+			fmt.Sprintf("Checksum mismatch for %q", archive),
+			status.Error(fmt.Sprintf("expected SHA-256 %s, got %s", expectedSum, sum)),
+			nil,
+		)
+		return false
+	}
+
+	// Files from the previous extraction which are not in the archive anymore
+	// are removed after extraction
+	previous, _ := a.readManifest()
+
+	x := &archiveExtractor{
+		dest:  dest,
+		strip: strip,
+		uid:   -1,
+		gid:   -1,
+		files: map[string]string{},
+	}
+	if owner := a.vars.Replace(a.owner); owner != "" {
+		userData, err := external.GetUser(owner)
+		if err != nil {
+			a.msg(status.StatusFailed, fmt.Sprintf("Could not get user information for %q", owner), status.Error(err.Error()), nil)
+			return false
+		}
+		x.uid = userData.ID
+		x.gid = userData.GID
+	}
+
+	a.msg(status.StatusRunning, fmt.Sprintf("Extracting %q into %q", archive, dest), nil, nil)
+
+	if err := x.mkdirAll("."); err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", dest), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if format == ".zip" {
+		err = x.extractZip(localPath)
+	} else {
+		err = x.extractTar(localPath, format)
+	}
+	if err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not extract %q into %q", archive, dest), status.Error(err.Error()), nil)
+		return false
+	}
+
+	for name := range previous.Files {
+		if _, ok := x.files[name]; ok {
+			continue
+		}
+		if err := x.remove(name); err != nil {
+			a.msg(status.StatusFailed, fmt.Sprintf("Could not remove %q", filepath.Join(dest, name)), status.Error(err.Error()), nil)
+			return false
+		}
+	}
+
+	manifestB, err := json.Marshal(archiveManifest{
+		Archive: archive,
+		Sum:     sum,
+		Strip:   strip,
+		Files:   x.files,
+	})
+	if err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not generate manifest for %q", dest), status.Error(err.Error()), nil)
+		return false
+	}
+	if err := os.MkdirAll(archiveManifestsDir, 0o700); err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", archiveManifestsDir), status.Error(err.Error()), nil)
+		return false
+	}
+	if err := os.WriteFile(a.manifestPath(), manifestB, 0o600); err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not write manifest for %q", dest), status.Error(err.Error()), nil)
+		return false
+	}
+
+	a.msg(status.StatusApplied, fmt.Sprintf("Extracted %d files from %q into %q", len(x.files), archive, dest), nil, nil)
+	return true
+}
+
+// download downloads the archive into a temporary file and returns its path.
+func (a *archiveExtract) download(url, format string) (string, bool) {
+	tmp, err := os.CreateTemp("", "keepakonf-*"+format)
+	if err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not create temporary file for %q", url), status.Error(err.Error()), nil)
+		return "", false
+	}
+
+	info := fmt.Sprintf("Downloading %q", url)
+	a.msg(status.StatusRunning, info, nil, nil)
+
+	err = external.Download(url, tmp, func(done, total int64) {
+		progress := formatSize(done)
+		if total >= 0 {
+			progress += " / " + formatSize(total)
+		}
+		a.msg(status.StatusRunning, info, status.Text(progress), nil)
+	})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not download %q", url), status.Error(err.Error()), nil)
+		return "", false
+	}
+
+	return tmp.Name(), true
+}
+
+// archiveFormat returns the archive type, according to its extension.
+func archiveFormat(name string) (string, error) {
+	name, _, _ = strings.Cut(strings.ToLower(name), "?")
+	for _, format := range []struct{ ext, format string }{
+		{".tar.gz", ".tar.gz"},
+		{".tgz", ".tar.gz"},
+		{".tar.bz2", ".tar.bz2"},
+		{".tbz2", ".tar.bz2"},
+		{".tar", ".tar"},
+		{".zip", ".zip"},
+	} {
+		if strings.HasSuffix(name, format.ext) {
+			return format.format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported archive format for %q", name)
+}
+
+type archiveExtractor struct {
+	dest  string
+	strip int
+	uid   int
+	gid   int
+	files map[string]string
+}
+
+// relPath returns the destination path of an archive entry, relative to the
+// destination directory, or "" if the entry is stripped.
+func (x *archiveExtractor) relPath(name string) (string, error) {
+	parts := []string{}
+	for _, part := range strings.Split(path.Clean("/"+filepath.ToSlash(name)), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) <= x.strip {
+		return "", nil
+	}
+	rel := filepath.Join(parts[x.strip:]...)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid path %q in archive", name)
+	}
+	return rel, nil
+}
+
+func (x *archiveExtractor) chown(fullPath string) error {
+	if x.uid < 0 {
+		return nil
+	}
+	return os.Lchown(fullPath, x.uid, x.gid)
+}
+
+// mkdirAll creates rel and its missing parents in the destination directory.
+// Callers must check rel with checkNoSymlinkParent first.
+func (x *archiveExtractor) mkdirAll(rel string) error {
+	fullPath := filepath.Join(x.dest, rel)
+	if rel == "." {
+		if finfo, err := os.Stat(fullPath); err == nil {
+			if !finfo.IsDir() {
+				return fmt.Errorf("%q is not a directory", fullPath)
+			}
+			return nil
+		}
+		if err := os.MkdirAll(fullPath, 0o755); err != nil {
+			return err
+		}
+		return x.chown(fullPath)
+	}
+	if finfo, err := os.Lstat(fullPath); err == nil {
+		if !finfo.IsDir() {
+			return fmt.Errorf("%q is not a directory", fullPath)
+		}
+		return nil
+	}
+	if err := x.mkdirAll(filepath.Dir(rel)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(fullPath, 0o755); err != nil {
+		return err
+	}
+	return x.chown(fullPath)
+}
+
+// prepare returns the path of an archive entry relative to the destination
+// directory, or "" if it is stripped, and creates its parent directories.
+func (x *archiveExtractor) prepare(name string) (string, error) {
+	rel, err := x.relPath(name)
+	if err != nil || rel == "" {
+		return "", err
+	}
+	if err := checkNoSymlinkParent(x.dest, rel); err != nil {
+		return "", err
+	}
+	if err := x.mkdirAll(filepath.Dir(rel)); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+func (x *archiveExtractor) dir(name string) error {
+	rel, err := x.prepare(name)
+	if err != nil || rel == "" {
+		return err
+	}
+	return x.mkdirAll(rel)
+}
+
+func (x *archiveExtractor) file(name string, mode fs.FileMode, r io.Reader) error {
+	rel, err := x.prepare(name)
+	if err != nil || rel == "" {
+		return err
+	}
+
+	// Remove does not follow symbolic links, and O_EXCL and O_NOFOLLOW make
+	// sure a link created meanwhile is not followed either
+	fullPath := filepath.Join(x.dest, rel)
+	if err := os.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, mode.Perm())
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), r)
+	if err == nil {
+		err = f.Chmod(mode.Perm())
+	}
+	if err == nil && x.uid >= 0 {
+		err = f.Chown(x.uid, x.gid)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	x.files[rel] = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+func (x *archiveExtractor) symlink(name, target string) error {
+	rel, err := x.prepare(name)
+	if err != nil || rel == "" {
+		return err
+	}
+
+	// Only a file or a link may be replaced, never a whole directory
+	fullPath := filepath.Join(x.dest, rel)
+	finfo, err := os.Lstat(fullPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case finfo.IsDir():
+		return fmt.Errorf("%q is a directory, cannot replace it with a symbolic link", rel)
+	default:
+		if err := os.Remove(fullPath); err != nil {
+			return err
+		}
+	}
+	if err := os.Symlink(target, fullPath); err != nil {
+		return err
+	}
+
+	x.files[rel] = "->" + target
+	return x.chown(fullPath)
+}
+
+// unsupported returns an error for an archive entry which cannot be
+// extracted, unless it is stripped, so that the extraction is never partial.
+func (x *archiveExtractor) unsupported(name, kind string) error {
+	rel, err := x.relPath(name)
+	if err != nil || rel == "" {
+		return err
+	}
+	return fmt.Errorf("%q is a %s, which is not supported", name, kind)
+}
+
+// remove removes a file from a previous extraction, and its parent
+// directories if they are empty.
+func (x *archiveExtractor) remove(rel string) error {
+	if !filepath.IsLocal(rel) {
+		return fmt.Errorf("invalid path %q in manifest", rel)
+	}
+	if err := checkNoSymlinkParent(x.dest, rel); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(x.dest, rel)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+		// Fails if the directory is not empty
+		if os.Remove(filepath.Join(x.dest, dir)) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) extractTar(archivePath, format string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch format {
+	case ".tar.gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case ".tar.bz2":
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(hdr.Name)
+		case tar.TypeReg:
+			err = x.file(hdr.Name, hdr.FileInfo().Mode(), tr)
+		case tar.TypeSymlink:
+			err = x.symlink(hdr.Name, hdr.Linkname)
+		case tar.TypeXGlobalHeader:
+			// Metadata for the following entries, not a file
+		case tar.TypeLink:
+			err = x.unsupported(hdr.Name, "hard link")
+		case tar.TypeChar, tar.TypeBlock:
+			err = x.unsupported(hdr.Name, "device")
+		case tar.TypeFifo:
+			err = x.unsupported(hdr.Name, "named pipe")
+		default:
+			err = x.unsupported(hdr.Name, fmt.Sprintf("tar entry of type %q", hdr.Typeflag))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (x *archiveExtractor) extractZip(archivePath string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.dir(zf.Name)
+		case mode&fs.ModeSymlink != 0:
+			err = x.zipSymlink(zf)
+		case mode.IsRegular():
+			err = x.zipFile(zf)
+		default:
+			err = x.unsupported(zf.Name, "special file")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) zipFile(zf *zip.File) error {
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return x.file(zf.Name, zf.Mode(), r)
+}
+
+func (x *archiveExtractor) zipSymlink(zf *zip.File) error {
+	r, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	target, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return x.symlink(zf.Name, string(target))
+}
@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"archive/tar"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type archiveTestEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+func writeTestTar(t *testing.T, entries []archiveTestEntry) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0o644,
+			Size:     int64(len(entry.content)),
+		}
+		if entry.typeflag == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestExtractor(t *testing.T, strip int) *archiveExtractor {
+	t.Helper()
+
+	x := &archiveExtractor{
+		dest:  filepath.Join(t.TempDir(), "dest"),
+		strip: strip,
+		uid:   -1,
+		gid:   -1,
+		files: map[string]string{},
+	}
+	if err := x.mkdirAll("."); err != nil {
+		t.Fatal(err)
+	}
+	return x
+}
+
+func TestArchiveExtractorRelPath(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		strip    int
+		expected string
+	}{
+		{"a/b/c", 0, "a/b/c"},
+		{"a/b/c", 1, "b/c"},
+		{"a/b", 2, ""},
+		{"../../etc/passwd", 0, "etc/passwd"},
+		{"/etc/passwd", 0, "etc/passwd"},
+		{"./a/./b/", 0, "a/b"},
+	} {
+		x := &archiveExtractor{strip: tc.strip}
+		rel, err := x.relPath(tc.name)
+		if err != nil {
+			t.Errorf("relPath(%q) returned error %v", tc.name, err)
+			continue
+		}
+		if rel != filepath.FromSlash(tc.expected) {
+			t.Errorf("relPath(%q) with strip %d = %q, expected %q", tc.name, tc.strip, rel, tc.expected)
+		}
+	}
+}
+
+func TestArchiveExtractorSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+
+	for name, entries := range map[string][]archiveTestEntry{
+		"file through link": {
+			{name: "a", typeflag: tar.TypeSymlink, linkname: outside},
+			{name: "a/passwd", typeflag: tar.TypeReg, content: "pwned"},
+		},
+		"directory through link": {
+			{name: "a", typeflag: tar.TypeSymlink, linkname: outside},
+			{name: "a/sub/", typeflag: tar.TypeDir},
+		},
+		"link through link": {
+			{name: "a", typeflag: tar.TypeSymlink, linkname: outside},
+			{name: "a/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+		},
+		"nested link": {
+			{name: "d/", typeflag: tar.TypeDir},
+			{name: "d/a", typeflag: tar.TypeSymlink, linkname: outside},
+			{name: "d/a/passwd", typeflag: tar.TypeReg, content: "pwned"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			x := newTestExtractor(t, 0)
+			if err := x.extractTar(writeTestTar(t, entries), ".tar"); err == nil {
+				t.Error("extraction through a symbolic link succeeded")
+			}
+			for _, leaked := range []string{"passwd", "sub"} {
+				if _, err := os.Lstat(filepath.Join(outside, leaked)); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("%q written outside the destination", leaked)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveExtractorOverwriteSymlink(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(outside, []byte("original"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	x := newTestExtractor(t, 0)
+	err := x.extractTar(writeTestTar(t, []archiveTestEntry{
+		{name: "f", typeflag: tar.TypeSymlink, linkname: outside},
+		{name: "f", typeflag: tar.TypeReg, content: "replaced"},
+	}), ".tar")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if content, _ := os.ReadFile(outside); string(content) != "original" {
+		t.Errorf("symbolic link target overwritten with %q", content)
+	}
+	finfo, err := os.Lstat(filepath.Join(x.dest, "f"))
+	if err != nil || !finfo.Mode().IsRegular() {
+		t.Errorf("expected a regular file replacing the symbolic link, got %v, %v", finfo, err)
+	}
+}
+
+func TestArchiveExtractorRemove(t *testing.T) {
+	x := newTestExtractor(t, 0)
+	err := x.extractTar(writeTestTar(t, []archiveTestEntry{
+		{name: "keep", typeflag: tar.TypeReg, content: "keep"},
+		{name: "old/stale", typeflag: tar.TypeReg, content: "stale"},
+	}), ".tar")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := x.remove(filepath.Join("old", "stale")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(x.dest, "old")); !errors.Is(err, fs.ErrNotExist) {
+		t.Error("empty parent directory not removed")
+	}
+	if _, err := os.Lstat(filepath.Join(x.dest, "keep")); err != nil {
+		t.Errorf("other file removed: %v", err)
+	}
+
+	if err := x.remove(filepath.Join("..", "outside")); err == nil {
+		t.Error("removing a file outside the destination succeeded")
+	}
+}
+
+func TestArchiveExtractorUnsupported(t *testing.T) {
+	for name, entries := range map[string][]archiveTestEntry{
+		"hard link": {
+			{name: "f", typeflag: tar.TypeReg, content: "content"},
+			{name: "g", typeflag: tar.TypeLink, linkname: "f"},
+		},
+		"device": {
+			{name: "dev", typeflag: tar.TypeChar},
+		},
+		"named pipe": {
+			{name: "fifo", typeflag: tar.TypeFifo},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			x := newTestExtractor(t, 0)
+			if err := x.extractTar(writeTestTar(t, entries), ".tar"); err == nil {
+				t.Error("extraction with an unsupported entry succeeded")
+			}
+		})
+	}
+
+	// Stripped entries are not extracted at all
+	x := newTestExtractor(t, 1)
+	err := x.extractTar(writeTestTar(t, []archiveTestEntry{
+		{name: "hardlink", typeflag: tar.TypeLink, linkname: "top/f"},
+		{name: "top/f", typeflag: tar.TypeReg, content: "content"},
+	}), ".tar")
+	if err != nil {
+		t.Errorf("unexpected error for a stripped entry: %v", err)
+	}
+}
+
+func TestArchiveExtractorSymlinkOverDirectory(t *testing.T) {
+	x := newTestExtractor(t, 0)
+	err := x.extractTar(writeTestTar(t, []archiveTestEntry{
+		{name: "d/", typeflag: tar.TypeDir},
+		{name: "d/keep", typeflag: tar.TypeReg, content: "keep"},
+		{name: "d", typeflag: tar.TypeSymlink, linkname: "/tmp"},
+	}), ".tar")
+	if err == nil {
+		t.Error("replacing a directory with a symbolic link succeeded")
+	}
+	if _, err := os.Lstat(filepath.Join(x.dest, "d", "keep")); err != nil {
+		t.Errorf("directory content removed: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	autostartUserDir   = ".config/autostart"
+	autostartSystemDir = "/etc/xdg/autostart"
+)
+
+var _ = registerFileWatcher(
+	"autostart entry",
+	"autostart",
+	"Start an application at login",
+	ParamsDesc{
+		{"user", "User (ignored if system-wide and enabled)", ParamTypeUsername},
+		{"system", "System-wide, for all users", ParamTypeBool},
+		{"name", "Entry name", ParamTypeString},
+		{"exec", "Command", ParamTypeString},
+		{"conditions", "Additional keys (Key=Value)", ParamTypeStringArray},
+		{"enabled", "Enabled", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &autostartEntry{
+			msg:        msg,
+			vars:       vars,
+			user:       params["user"].(string),
+			system:     params["system"].(bool),
+			name:       params["name"].(string),
+			exec:       params["exec"].(string),
+			conditions: params["conditions"].([]string),
+			enabled:    params["enabled"].(bool),
+		}
+	},
+)
+
+type autostartEntry struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	user       string
+	system     bool
+	name       string
+	exec       string
+	conditions []string
+	enabled    bool
+}
+
+func (a *autostartEntry) updateVariables(vars variables.Variables) (changed bool) {
+	return a.vars.Update(vars)
+}
+
+// inSystemDir returns true if the entry is written to the system-wide
+// directory. A disabled system-wide entry may belong to a package, it is not
+// replaced but overridden from the user directory instead.
+func (a *autostartEntry) inSystemDir() bool {
+	return a.system && a.enabled
+}
+
+func (a *autostartEntry) getPath() string {
+	filename := a.vars.Replace(a.name) + ".desktop"
+	if a.inSystemDir() {
+		return filepath.Join(autostartSystemDir, filename)
+	}
+	return userHomePath(a.vars.Replace(a.user), filepath.Join(autostartUserDir, filename))
+}
+
+// content returns the expected desktop entry. A disabled entry is kept with
+// Hidden=true, which also overrides a system-wide entry with the same name
+// when placed in the user directory.
+func (a *autostartEntry) content() string {
+	var content strings.Builder
+	content.WriteString("# Placed by Keepakonf\n")
+	content.WriteString("[Desktop Entry]\n")
+	content.WriteString("Type=Application\n")
+	content.WriteString("Name=" + a.vars.Replace(a.name) + "\n")
+	content.WriteString("Exec=" + a.vars.Replace(a.exec) + "\n")
+	for _, condition := range a.vars.ReplaceSlice(a.conditions) {
+		if condition = strings.TrimSpace(condition); condition != "" {
+			content.WriteString(condition + "\n")
+		}
+	}
+	if !a.enabled {
+		content.WriteString("Hidden=true\n")
+	}
+	return content.String()
+}
+
+func (a *autostartEntry) description() string {
+	name := a.vars.Replace(a.name)
+	if a.inSystemDir() {
+		return name
+	}
+	return name + " for " + a.vars.Replace(a.user)
+}
+
+// validate returns an error if the parameters cannot be applied.
+func (a *autostartEntry) validate() error {
+	if !a.inSystemDir() && a.vars.Replace(a.user) == "" {
+		return errors.New("user must not be empty")
+	}
+	return nil
+}
+
+func (a *autostartEntry) newStatus(fstatus external.FileStatus) {
+	if err := a.validate(); err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return
+	}
+
+	switch fstatus {
+	case external.FileStatusDirectory:
+		a.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", a.getPath()), nil, nil)
+	case external.FileStatusFile:
+		currentB, err := os.ReadFile(a.getPath())
+		if err != nil {
+			a.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", a.getPath()), status.Error(err.Error()), nil)
+			return
+		}
+		current := string(currentB)
+		if current != a.content() {
+			a.msg(status.StatusTodo, "Need to change autostart entry "+a.description(), status.TextDiff{Before: current, After: a.content()}, nil)
+			return
+		}
+		if a.enabled {
+			a.msg(status.StatusApplied, "Autostart entry "+a.description()+" is enabled", status.Text(current), nil)
+		} else {
+			a.msg(status.StatusApplied, "Autostart entry "+a.description()+" is disabled", status.Text(current), nil)
+		}
+	case external.FileStatusUnknown:
+		a.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", a.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		a.msg(status.StatusTodo, "Need to create autostart entry "+a.description(), status.Text(a.content()), nil)
+	}
+}
+
+func (a *autostartEntry) apply() bool {
+	if err := a.validate(); err != nil {
+		a.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	path := a.getPath()
+
+	owner := ""
+	if !a.inSystemDir() {
+		owner = a.vars.Replace(a.user)
+	}
+
+	if err := mkdirAllOwned(filepath.Dir(path), 0o755, owner); err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", filepath.Dir(path)), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if err := installFile(strings.NewReader(a.content()), path, 0o644, owner); err != nil {
+		a.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	a.msg(status.StatusApplied, "Wrote autostart entry "+a.description(), status.Text(a.content()), nil)
+	return true
+}
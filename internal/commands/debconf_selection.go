@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+// Format: owner question type value
+var debconfSelectionRe = regexp.MustCompile(`^\s*(\S+)\s+(\S+)\s+(\S+)\s*(.*?)\s*$`)
+
+var _ = registerFileWatcher(
+	"debconf selection",
+	"ubuntu",
+	"Preseed answers to packages questions",
+	ParamsDesc{
+		{"selections", "Selections (owner question type value)", ParamTypeStringArray},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &debconfSelection{
+			msg:        msg,
+			vars:       vars,
+			selections: params["selections"].([]string),
+		}
+	},
+)
+
+type debconfAnswer struct {
+	owner    string
+	question string
+	qtype    string
+	value    string
+}
+
+type debconfSelection struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	selections []string
+}
+
+func (d *debconfSelection) updateVariables(vars variables.Variables) (changed bool) {
+	return d.vars.Update(vars)
+}
+
+func (d *debconfSelection) getPath() string {
+	return external.DebconfConfigPath
+}
+
+func (d *debconfSelection) answers() ([]debconfAnswer, error) {
+	answers := []debconfAnswer{}
+	for _, line := range d.vars.ReplaceSlice(d.selections) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		matches := debconfSelectionRe.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid selection %q, expected \"owner question type value\"", line)
+		}
+		answers = append(answers, debconfAnswer{matches[1], matches[2], matches[3], matches[4]})
+	}
+	return answers, nil
+}
+
+func (d *debconfSelection) newStatus(fstatus external.FileStatus) {
+	switch fstatus {
+	case external.FileStatusFile:
+		d.msg(d.check())
+	case external.FileStatusDirectory:
+		d.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", d.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		d.msg(status.StatusFailed, fmt.Sprintf("File %q not found", d.getPath()), nil, nil)
+	case external.FileStatusUnknown:
+		d.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", d.getPath()), nil, nil)
+	}
+}
+
+func (d *debconfSelection) check() (status.Status, string, status.Detail, variables.Variables) {
+	answers, err := d.answers()
+	if err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	values, err := external.DebconfValues()
+	if err != nil {
+		return status.StatusFailed, "Could not read debconf database", status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"Question", "Type", "Current", "Wanted"},
+	}
+
+	todo := []string{}
+	for _, answer := range answers {
+		current, known := values[answer.question]
+		rowStatus := status.StatusApplied
+		if !known || current != answer.value {
+			rowStatus = status.StatusTodo
+			todo = append(todo, answer.question)
+		}
+
+		currentContent, wantedContent := current, answer.value
+		if answer.qtype == "password" {
+			currentContent, wantedContent = "Hidden", "Hidden"
+		}
+		if !known {
+			currentContent = "Not set"
+		}
+
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: answer.question},
+			status.TableCell{Status: status.StatusNone, Content: answer.qtype},
+			status.TableCell{Status: rowStatus, Content: currentContent},
+			status.TableCell{Status: status.StatusNone, Content: wantedContent},
+		)
+	}
+
+	if len(todo) > 0 {
+		return status.StatusTodo, "Need to set " + strings.Join(todo, ", "), &result, nil
+	}
+	return status.StatusApplied, "debconf answers are as expected", &result, nil
+}
+
+func (d *debconfSelection) apply() bool {
+	answers, err := d.answers()
+	if err != nil {
+		d.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	tmp, err := os.CreateTemp("", "keepakonf-debconf-*")
+	if err != nil {
+		d.msg(status.StatusFailed, "Could not create temporary file for debconf selections", status.Error(err.Error()), nil)
+		return false
+	}
+	defer os.Remove(tmp.Name())
+
+	for _, answer := range answers {
+		if _, err := fmt.Fprintf(tmp, "%s %s %s %s\n", answer.owner, answer.question, answer.qtype, answer.value); err != nil {
+			tmp.Close()
+			d.msg(status.StatusFailed, "Could not write debconf selections", status.Error(err.Error()), nil)
+			return false
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		d.msg(status.StatusFailed, "Could not write debconf selections", status.Error(err.Error()), nil)
+		return false
+	}
+
+	return external.DebconfSetSelections(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = "Setting debconf answers"
+				case status.StatusApplied:
+					info = "Successfully set debconf answers"
+				case status.StatusFailed:
+					info = "Failed setting debconf answers"
+				}
+			}
+			d.msg(s, info, detail, nil)
+		},
+		tmp.Name(),
+	)
+}
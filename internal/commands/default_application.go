@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	mimeappsPath           = ".config/mimeapps.list"
+	mimeappsDefaultSection = "[Default Applications]"
+)
+
+// applicationsDataDirs are the system XDG data directories containing desktop
+// files, the user one being added in front of them.
+var applicationsDataDirs = []string{
+	"/usr/local/share/applications",
+	"/usr/share/applications",
+	"/var/lib/flatpak/exports/share/applications",
+	"/var/lib/snapd/desktop/applications",
+}
+
+var _ = registerFileWatcher(
+	"default application",
+	"file",
+	"Set the default application for MIME types",
+	ParamsDesc{
+		{"user", "User", ParamTypeUsername},
+		{"mimetypes", "MIME types", ParamTypeStringArray},
+		{"application", "Desktop file ID", ParamTypeString},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &defaultApplication{
+			msg:         msg,
+			vars:        vars,
+			user:        params["user"].(string),
+			mimetypes:   params["mimetypes"].([]string),
+			application: params["application"].(string),
+		}
+	},
+)
+
+type defaultApplication struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	user        string
+	mimetypes   []string
+	application string
+}
+
+func (d *defaultApplication) updateVariables(vars variables.Variables) (changed bool) {
+	return d.vars.Update(vars)
+}
+
+func (d *defaultApplication) getPath() string {
+	return userHomePath(d.vars.Replace(d.user), mimeappsPath)
+}
+
+func (d *defaultApplication) desktopID() string {
+	id := d.vars.Replace(d.application)
+	if !strings.HasSuffix(id, ".desktop") {
+		id += ".desktop"
+	}
+	return id
+}
+
+// desktopFileExists returns true if the desktop file is found in the user or
+// system XDG data directories.
+func (d *defaultApplication) desktopFileExists() bool {
+	dirs := append(
+		[]string{userHomePath(d.vars.Replace(d.user), ".local/share/applications")},
+		applicationsDataDirs...,
+	)
+	id := d.desktopID()
+	for _, dir := range dirs {
+		// Desktop file IDs replace subdirectories with dashes
+		for _, candidate := range []string{id, strings.Replace(id, "-", "/", 1)} {
+			if info, err := os.Stat(filepath.Join(dir, candidate)); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// currentDefaults returns the lines of mimeapps.list, and the handlers in its
+// default applications section.
+func (d *defaultApplication) currentDefaults() ([]string, map[string]string, error) {
+	content, err := readOptionalFile(d.getPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lines := []string{}
+	if content != "" {
+		lines = strings.Split(strings.TrimRight(content, "\n"), "\n")
+	}
+
+	defaults := map[string]string{}
+	var inSection bool
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inSection = line == mimeappsDefaultSection
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if mimetype, handlers, ok := strings.Cut(line, "="); ok {
+			// The first handler in the list is the one used
+			handler, _, _ := strings.Cut(handlers, ";")
+			defaults[strings.TrimSpace(mimetype)] = strings.TrimSpace(handler)
+		}
+	}
+
+	return lines, defaults, nil
+}
+
+func (d *defaultApplication) newStatus(fstatus external.FileStatus) {
+	switch fstatus {
+	case external.FileStatusDirectory:
+		d.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", d.getPath()), nil, nil)
+	case external.FileStatusUnknown:
+		d.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", d.getPath()), nil, nil)
+	default:
+		d.msg(d.check())
+	}
+}
+
+func (d *defaultApplication) check() (status.Status, string, status.Detail, variables.Variables) {
+	id := d.desktopID()
+	user := d.vars.Replace(d.user)
+
+	_, defaults, err := d.currentDefaults()
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not read %q", d.getPath()), status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"MIME type", "Current", "Expected"},
+	}
+
+	var todo int
+	for _, mimetype := range d.vars.ReplaceSlice(d.mimetypes) {
+		current, ok := defaults[mimetype]
+		rowStatus := status.StatusApplied
+		if current != id {
+			rowStatus = status.StatusTodo
+			todo++
+		}
+		if !ok {
+			current = "None"
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: mimetype},
+			status.TableCell{Status: rowStatus, Content: current},
+			status.TableCell{Status: status.StatusNone, Content: id},
+		)
+	}
+
+	if !d.desktopFileExists() {
+		return status.StatusFailed, fmt.Sprintf("Desktop file %q not found", id), &result, nil
+	}
+
+	if todo > 0 {
+		return status.StatusTodo, fmt.Sprintf("Need to set %s as default for %d MIME types for %s", id, todo, user), &result, nil
+	}
+	return status.StatusApplied, fmt.Sprintf("%s is the default application for %s", id, user), &result, nil
+}
+
+func (d *defaultApplication) apply() bool {
+	path := d.getPath()
+	id := d.desktopID()
+	user := d.vars.Replace(d.user)
+
+	if !d.desktopFileExists() {
+		d.msg(status.StatusFailed, fmt.Sprintf("Desktop file %q not found", id), nil, nil)
+		return false
+	}
+
+	lines, _, err := d.currentDefaults()
+	if err != nil {
+		d.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	wanted := map[string]struct{}{}
+	for _, mimetype := range d.vars.ReplaceSlice(d.mimetypes) {
+		wanted[mimetype] = struct{}{}
+	}
+
+	// Replace the managed MIME types in the default applications section,
+	// then add the remaining ones at the end of the section
+	newLines := []string{}
+	sectionEnd := -1
+	var inSection bool
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			if inSection {
+				sectionEnd = len(newLines)
+			}
+			inSection = trimmed == mimeappsDefaultSection
+		} else if inSection {
+			if mimetype, _, ok := strings.Cut(trimmed, "="); ok {
+				if _, ok := wanted[strings.TrimSpace(mimetype)]; ok {
+					continue
+				}
+			}
+		}
+		newLines = append(newLines, line)
+	}
+	if inSection {
+		sectionEnd = len(newLines)
+	}
+
+	added := []string{}
+	for _, mimetype := range d.vars.ReplaceSlice(d.mimetypes) {
+		added = append(added, mimetype+"="+id)
+	}
+
+	if sectionEnd == -1 {
+		if len(newLines) > 0 {
+			newLines = append(newLines, "")
+		}
+		newLines = append(newLines, mimeappsDefaultSection)
+		newLines = append(newLines, added...)
+	} else {
+		// Keep blank lines separating sections after the added entries
+		for sectionEnd > 0 && strings.TrimSpace(newLines[sectionEnd-1]) == "" {
+			sectionEnd--
+		}
+		newLines = append(newLines[:sectionEnd], append(added, newLines[sectionEnd:]...)...)
+	}
+
+	if err := mkdirAllOwned(filepath.Dir(path), 0o755, user); err != nil {
+		d.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", filepath.Dir(path)), status.Error(err.Error()), nil)
+		return false
+	}
+
+	// The file is written through a temporary file owned by the user, so that
+	// a newly created mimeapps.list belongs to them too
+	if err := installFile(strings.NewReader(strings.Join(newLines, "\n")+"\n"), path, 0o644, user); err != nil {
+		d.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	d.msg(status.StatusApplied, fmt.Sprintf("Set %s as default application for %s", id, user), nil, nil)
+	return true
+}
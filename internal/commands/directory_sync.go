@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/willoma/keepakonf/internal/assets"
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = registerFileWatcher(
+	"directory sync",
+	"folder",
+	"Synchronize a directory with an uploaded directory",
+	ParamsDesc{
+		{"asset", "Directory", ParamTypeAssetDir},
+		{"destination", "Destination directory", ParamTypeFilePath},
+		{"mode", "Files mode", ParamTypeString},
+		{"owner", "Files owner", ParamTypeUsername},
+		{"delete", "Delete other files", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &directorySync{
+			msg:         msg,
+			vars:        vars,
+			asset:       params["asset"].(string),
+			destination: params["destination"].(string),
+			mode:        params["mode"].(string),
+			owner:       params["owner"].(string),
+			delete:      params["delete"].(bool),
+		}
+	},
+)
+
+type directorySync struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	asset       string
+	destination string
+	mode        string
+	owner       string
+	delete      bool
+}
+
+// directorySyncChange is a difference between the directory and the asset.
+type directorySyncChange struct {
+	rel    string
+	asset  string
+	status string
+}
+
+func (d *directorySync) updateVariables(vars variables.Variables) (changed bool) {
+	return d.vars.Update(vars)
+}
+
+func (d *directorySync) getPath() string {
+	return d.vars.Replace(d.destination)
+}
+
+// changes returns the files to copy or to remove.
+func (d *directorySync) changes(manifest assets.Manifest, mode fs.FileMode) ([]directorySyncChange, error) {
+	dest := d.getPath()
+	changes := []directorySyncChange{}
+
+	expected := map[string]struct{}{}
+	for _, file := range manifest.Files {
+		rel := filepath.FromSlash(file.Path)
+		expected[rel] = struct{}{}
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			expected[dir] = struct{}{}
+		}
+
+		fullPath := filepath.Join(dest, rel)
+		finfo, err := os.Stat(fullPath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				changes = append(changes, directorySyncChange{rel, file.Asset, "Missing"})
+				continue
+			}
+			return nil, err
+		}
+		sum, err := sha256File(fullPath)
+		switch {
+		case err != nil:
+			return nil, err
+		case sum != file.Asset:
+			changes = append(changes, directorySyncChange{rel, file.Asset, "Modified"})
+		case finfo.Mode().Perm() != mode:
+			changes = append(changes, directorySyncChange{rel, file.Asset, "Wrong mode"})
+		}
+	}
+
+	if !d.delete {
+		return changes, nil
+	}
+
+	err := filepath.WalkDir(dest, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if _, ok := expected[rel]; ok {
+			return nil
+		}
+		changes = append(changes, directorySyncChange{rel, "", "Unexpected"})
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return changes, err
+}
+
+func (d *directorySync) newStatus(fstatus external.FileStatus) {
+	switch fstatus {
+	case external.FileStatusFile:
+		d.msg(status.StatusFailed, fmt.Sprintf("%q is not a directory", d.getPath()), nil, nil)
+	case external.FileStatusUnknown:
+		d.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", d.getPath()), nil, nil)
+	default:
+		d.msg(d.check())
+	}
+}
+
+func (d *directorySync) check() (status.Status, string, status.Detail, variables.Variables) {
+	dest := d.getPath()
+
+	mode, err := parseMode(d.vars.Replace(d.mode), 0o644)
+	if err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	manifest, err := assets.ReadManifest(d.asset)
+	if err != nil {
+		return status.StatusFailed, "Could not read uploaded directory", status.Error(err.Error()), nil
+	}
+
+	changes, err := d.changes(manifest, mode)
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not compare %q", dest), status.Error(err.Error()), nil
+	}
+
+	if len(changes) == 0 {
+		return status.StatusApplied, fmt.Sprintf("%d files synchronized in %q", len(manifest.Files), dest), nil, nil
+	}
+
+	result := status.Table{
+		Header: []string{"File", "Status"},
+	}
+	for _, change := range changes {
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: filepath.ToSlash(change.rel)},
+			status.TableCell{Status: status.StatusTodo, Content: change.status},
+		)
+	}
+	return status.StatusTodo, fmt.Sprintf("Need to synchronize %q, %d files changed", dest, len(changes)), &result, nil
+}
+
+func (d *directorySync) apply() bool {
+	dest := d.getPath()
+	owner := d.vars.Replace(d.owner)
+
+	mode, err := parseMode(d.vars.Replace(d.mode), 0o644)
+	if err != nil {
+		d.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	manifest, err := assets.ReadManifest(d.asset)
+	if err != nil {
+		d.msg(status.StatusFailed, "Could not read uploaded directory", status.Error(err.Error()), nil)
+		return false
+	}
+
+	changes, err := d.changes(manifest, mode)
+	if err != nil {
+		d.msg(status.StatusFailed, fmt.Sprintf("Could not compare %q", dest), status.Error(err.Error()), nil)
+		return false
+	}
+
+	d.msg(status.StatusRunning, fmt.Sprintf("Synchronizing %q", dest), nil, nil)
+
+	for _, change := range changes {
+		fullPath := filepath.Join(dest, change.rel)
+
+		if err := checkNoSymlinkParent(dest, change.rel); err != nil {
+			d.msg(status.StatusFailed, fmt.Sprintf("Could not synchronize %q", fullPath), status.Error(err.Error()), nil)
+			return false
+		}
+
+		if change.asset == "" {
+			if err := os.RemoveAll(fullPath); err != nil {
+				d.msg(status.StatusFailed, fmt.Sprintf("Could not remove %q", fullPath), status.Error(err.Error()), nil)
+				return false
+			}
+			continue
+		}
+
+		if err := d.mkdirAll(filepath.Dir(change.rel), owner); err != nil {
+			d.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", filepath.Dir(fullPath)), status.Error(err.Error()), nil)
+			return false
+		}
+		if err := installAsset(change.asset, fullPath, mode, owner); err != nil {
+			d.msg(status.StatusFailed, fmt.Sprintf("Could not copy file to %q", fullPath), status.Error(err.Error()), nil)
+			return false
+		}
+	}
+
+	d.msg(status.StatusApplied, fmt.Sprintf("Synchronized %d files in %q", len(manifest.Files), dest), nil, nil)
+	return true
+}
+
+// mkdirAll creates rel and its missing parents in the destination directory,
+// giving them to owner. Symbolic links are not followed, except for the
+// destination directory itself.
+func (d *directorySync) mkdirAll(rel, owner string) error {
+	fullPath := filepath.Join(d.getPath(), rel)
+	stat := os.Lstat
+	if rel == "." {
+		stat = os.Stat
+	}
+	if finfo, err := stat(fullPath); err == nil {
+		if !finfo.IsDir() {
+			return fmt.Errorf("%q is not a directory", fullPath)
+		}
+		return nil
+	}
+	if rel == "." {
+		if err := os.MkdirAll(fullPath, 0o755); err != nil {
+			return err
+		}
+	} else {
+		if err := d.mkdirAll(filepath.Dir(rel), owner); err != nil {
+			return err
+		}
+		if err := os.Mkdir(fullPath, 0o755); err != nil {
+			return err
+		}
+	}
+	if owner == "" {
+		return nil
+	}
+	return chownUser(fullPath, owner)
+}
@@ -35,6 +35,11 @@ type fileContent struct {
 }
 
 func (f *fileContent) newStatus(fstatus external.FileStatus) {
+	f.checkContent(fstatus, f.vars.Replace(f.content))
+}
+
+// checkContent sends the status of the file compared to the expected content.
+func (f *fileContent) checkContent(fstatus external.FileStatus, expected string) {
 	switch fstatus {
 	case external.FileStatusDirectory:
 		f.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", f.getPath()), nil, nil)
@@ -45,8 +50,8 @@ func (f *fileContent) newStatus(fstatus external.FileStatus) {
 			return
 		}
 		current := string(currentB)
-		if current != f.vars.Replace(f.content) {
-			f.msg(status.StatusTodo, fmt.Sprintf("Need to change %q", f.getPath()), status.TextDiff{Before: current, After: f.vars.Replace(f.content)}, nil)
+		if current != expected {
+			f.msg(status.StatusTodo, fmt.Sprintf("Need to change %q", f.getPath()), status.TextDiff{Before: current, After: expected}, nil)
 			return
 		}
 		// TODO check ownership
@@ -54,12 +59,17 @@ func (f *fileContent) newStatus(fstatus external.FileStatus) {
 	case external.FileStatusUnknown:
 		f.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", f.getPath()), nil, nil)
 	case external.FileStatusNotFound:
-		f.msg(status.StatusTodo, fmt.Sprintf("Need to create %q", f.getPath()), status.Text(f.vars.Replace(f.content)), nil)
+		f.msg(status.StatusTodo, fmt.Sprintf("Need to create %q", f.getPath()), status.Text(expected), nil)
 	}
 }
 
 func (f *fileContent) apply() bool {
-	if err := os.WriteFile(f.getPath(), []byte(f.vars.Replace(f.content)), 0x644); err != nil {
+	return f.writeContent(f.vars.Replace(f.content))
+}
+
+// writeContent writes content to the file and changes its ownership.
+func (f *fileContent) writeContent(content string) bool {
+	if err := os.WriteFile(f.getPath(), []byte(content), 0o644); err != nil {
 		f.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", f.getPath()), status.Error(err.Error()), nil)
 		return false
 	}
@@ -75,6 +85,6 @@ func (f *fileContent) apply() bool {
 		}
 	}
 
-	f.msg(status.StatusApplied, fmt.Sprintf("Wrote content to %q", f.getPath()), status.Text(f.content), nil)
+	f.msg(status.StatusApplied, fmt.Sprintf("Wrote content to %q", f.getPath()), status.Text(content), nil)
 	return true
 }
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/willoma/keepakonf/internal/assets"
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = registerFileWatcher(
+	"file copy",
+	"file",
+	"Copy an uploaded file",
+	ParamsDesc{
+		{"asset", "File", ParamTypeAsset},
+		{"path", "Destination path", ParamTypeFilePath},
+		{"mode", "File mode", ParamTypeString},
+		{"owner", "File owner", ParamTypeUsername},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &fileCopy{
+			fileWatcherCmdInit(params, vars, msg),
+			params["asset"].(string),
+			params["mode"].(string),
+			params["owner"].(string),
+		}
+	},
+)
+
+type fileCopy struct {
+	fileWatcherCmd
+
+	asset string
+	mode  string
+	owner string
+}
+
+func (f *fileCopy) newStatus(fstatus external.FileStatus) {
+	if _, err := assets.Path(f.asset); err != nil {
+		f.msg(status.StatusFailed, "No file uploaded", status.Error(err.Error()), nil)
+		return
+	}
+
+	switch fstatus {
+	case external.FileStatusDirectory:
+		f.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", f.getPath()), nil, nil)
+	case external.FileStatusFile:
+		mode, err := parseMode(f.vars.Replace(f.mode), 0o644)
+		if err != nil {
+			f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+			return
+		}
+
+		finfo, err := os.Stat(f.getPath())
+		if err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not check status of %q", f.getPath()), status.Error(err.Error()), nil)
+			return
+		}
+
+		currentSum, err := sha256File(f.getPath())
+		if err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", f.getPath()), status.Error(err.Error()), nil)
+			return
+		}
+
+		sumStatus := status.StatusApplied
+		if currentSum != f.asset {
+			sumStatus = status.StatusTodo
+		}
+		modeStatus := status.StatusApplied
+		if finfo.Mode().Perm() != mode {
+			modeStatus = status.StatusTodo
+		}
+
+		result := status.Table{
+			Header: []string{"", "Current", "Expected"},
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: "SHA-256"},
+			status.TableCell{Status: sumStatus, Content: currentSum},
+			status.TableCell{Status: status.StatusNone, Content: f.asset},
+		)
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: "Mode"},
+			status.TableCell{Status: modeStatus, Content: fmt.Sprintf("%04o", finfo.Mode().Perm())},
+			status.TableCell{Status: status.StatusNone, Content: fmt.Sprintf("%04o", mode)},
+		)
+
+		switch {
+		case sumStatus == status.StatusTodo:
+			f.msg(status.StatusTodo, fmt.Sprintf("Need to copy file to %q", f.getPath()), &result, nil)
+		case modeStatus == status.StatusTodo:
+			f.msg(status.StatusTodo, fmt.Sprintf("Need to change mode of %q", f.getPath()), &result, nil)
+		default:
+			f.msg(status.StatusApplied, fmt.Sprintf("%q has the expected content", f.getPath()), &result, nil)
+		}
+	case external.FileStatusUnknown:
+		f.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", f.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		f.msg(status.StatusTodo, fmt.Sprintf("Need to copy file to %q", f.getPath()), nil, nil)
+	}
+}
+
+func (f *fileCopy) apply() bool {
+	path := f.getPath()
+
+	mode, err := parseMode(f.vars.Replace(f.mode), 0o644)
+	if err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	if err := installAsset(f.asset, path, mode, f.vars.Replace(f.owner)); err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not copy file to %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	f.msg(status.StatusApplied, fmt.Sprintf("Copied file to %q", path), nil, nil)
+	return true
+}
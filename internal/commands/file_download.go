@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = registerFileWatcher(
+	"file download",
+	"download",
+	"Download a file and verify its checksum",
+	ParamsDesc{
+		{"url", "URL", ParamTypeString},
+		{"path", "Destination path", ParamTypeFilePath},
+		{"sha256", "Expected SHA-256", ParamTypeString},
+		{"mode", "File mode", ParamTypeString},
+		{"owner", "File owner", ParamTypeUsername},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &fileDownload{
+			fileWatcherCmdInit(params, vars, msg),
+			params["url"].(string),
+			params["sha256"].(string),
+			params["mode"].(string),
+			params["owner"].(string),
+		}
+	},
+)
+
+type fileDownload struct {
+	fileWatcherCmd
+
+	url    string
+	sha256 string
+	mode   string
+	owner  string
+}
+
+func (f *fileDownload) expectedSum() string {
+	return strings.ToLower(strings.TrimSpace(f.vars.Replace(f.sha256)))
+}
+
+// checkSum returns an error if the expected checksum is not a SHA-256 sum.
+func (f *fileDownload) checkSum() error {
+	sum := f.expectedSum()
+	if len(sum) != sha256.Size*2 {
+		return fmt.Errorf("SHA-256 %q must be %d hexadecimal characters", sum, sha256.Size*2)
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return fmt.Errorf("SHA-256 %q is not hexadecimal", sum)
+	}
+	return nil
+}
+
+func (f *fileDownload) newStatus(fstatus external.FileStatus) {
+	if err := f.checkSum(); err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return
+	}
+
+	switch fstatus {
+	case external.FileStatusDirectory:
+		f.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", f.getPath()), nil, nil)
+	case external.FileStatusFile:
+		f.msg(f.check())
+	case external.FileStatusUnknown:
+		f.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", f.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		f.msg(status.StatusTodo, fmt.Sprintf("Need to download %q", f.getPath()), nil, nil)
+	}
+}
+
+// check returns the status of an existing file: checksum, mode and owner.
+func (f *fileDownload) check() (status.Status, string, status.Detail, variables.Variables) {
+	path := f.getPath()
+
+	mode, err := parseMode(f.vars.Replace(f.mode), 0o644)
+	if err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	uid, gid, currentMode, err := fileOwnership(path)
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not check status of %q", path), status.Error(err.Error()), nil
+	}
+
+	currentSum, err := sha256File(path)
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not read %q", path), status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"", "Current", "Expected"},
+	}
+
+	sumStatus := status.StatusApplied
+	if currentSum != f.expectedSum() {
+		sumStatus = status.StatusTodo
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "SHA-256"},
+		status.TableCell{Status: sumStatus, Content: currentSum},
+		status.TableCell{Status: status.StatusNone, Content: f.expectedSum()},
+	)
+
+	modeStatus := status.StatusApplied
+	if currentMode != mode {
+		modeStatus = status.StatusTodo
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "Mode"},
+		status.TableCell{Status: modeStatus, Content: fmt.Sprintf("%04o", currentMode)},
+		status.TableCell{Status: status.StatusNone, Content: fmt.Sprintf("%04o", mode)},
+	)
+
+	ownerStatus := status.StatusApplied
+	if owner := f.vars.Replace(f.owner); owner != "" {
+		userData, err := external.GetUser(owner)
+		if err != nil {
+			return status.StatusFailed, fmt.Sprintf("Could not get user information for %q", owner), status.Error(err.Error()), nil
+		}
+		if uid != userData.ID || gid != userData.GID {
+			ownerStatus = status.StatusTodo
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: "Owner"},
+			status.TableCell{Status: ownerStatus, Content: fmt.Sprintf("%d:%d", uid, gid)},
+			status.TableCell{Status: status.StatusNone, Content: fmt.Sprintf("%s (%d:%d)", owner, userData.ID, userData.GID)},
+		)
+	}
+
+	switch {
+	case sumStatus == status.StatusTodo:
+		return status.StatusTodo, fmt.Sprintf("Need to download %q", path), &result, nil
+	case modeStatus == status.StatusTodo:
+		return status.StatusTodo, fmt.Sprintf("Need to change mode of %q", path), &result, nil
+	case ownerStatus == status.StatusTodo:
+		return status.StatusTodo, fmt.Sprintf("Need to change ownership of %q", path), &result, nil
+	default:
+		return status.StatusApplied, fmt.Sprintf("%q has the expected checksum", path), &result, nil
+	}
+}
+
+func (f *fileDownload) apply() bool {
+	path := f.getPath()
+	url := f.vars.Replace(f.url)
+
+	if err := f.checkSum(); err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	mode, err := parseMode(f.vars.Replace(f.mode), 0o644)
+	if err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	if currentSum, err := sha256File(path); err != nil || currentSum != f.expectedSum() {
+		if !f.download(url, path) {
+			return false
+		}
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not change mode of %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if owner := f.vars.Replace(f.owner); owner != "" {
+		if err := chownUser(path, owner); err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not change %q ownership to %q", path, owner), status.Error(err.Error()), nil)
+			return false
+		}
+	}
+
+	f.msg(status.StatusApplied, fmt.Sprintf("Downloaded %q to %q", url, path), nil, nil)
+	return true
+}
+
+// download downloads url into a temporary file next to path, verifies its
+// checksum, then renames it to path.
+func (f *fileDownload) download(url, path string) bool {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not create temporary file for %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+	defer os.Remove(tmp.Name())
+
+	info := fmt.Sprintf("Downloading %q", url)
+	f.msg(status.StatusRunning, info, nil, nil)
+
+	hash := sha256.New()
+	err = external.Download(
+		url,
+		io.MultiWriter(tmp, hash),
+		func(done, total int64) {
+			progress := formatSize(done)
+			if total >= 0 {
+				progress += fmt.Sprintf(" / %s (%d%%)", formatSize(total), done*100/max(total, 1))
+			}
+			f.msg(status.StatusRunning, info, status.Text(progress), nil)
+		},
+	)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not download %q", url), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != f.expectedSum() {
+		f.msg(
+			status.StatusFailed,
+			fmt.Sprintf("Checksum mismatch for %q", url),
+			status.Error(fmt.Sprintf("expected SHA-256 %s, got %s", f.expectedSum(), sum)),
+			nil,
+		)
+		return false
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not move downloaded file to %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+func TestFileDownload(t *testing.T) {
+	content := strings.Repeat("keepakonf", 1000)
+	hash := sha256.Sum256([]byte(content))
+	sum := hex.EncodeToString(hash[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	var lastStatus status.Status
+	vars := variables.Variables{}
+	vars.Define("owner", "")
+	path := filepath.Join(t.TempDir(), "file")
+	f := &fileDownload{
+		fileWatcherCmd: fileWatcherCmd{
+			msg: func(s status.Status, info string, detail status.Detail, outVars variables.Variables) {
+				lastStatus = s
+			},
+			vars: vars,
+			path: path,
+		},
+		url:    server.URL,
+		sha256: strings.ToUpper(sum),
+		mode:   "0640",
+		owner:  "<owner>",
+	}
+
+	expectCheck := func(t *testing.T, expected status.Status) {
+		t.Helper()
+		if s, info, _, _ := f.check(); s != expected {
+			t.Errorf("check() = %s (%s), expected %s", s, info, expected)
+		}
+	}
+
+	f.newStatus(external.FileStatusNotFound)
+	if lastStatus != status.StatusTodo {
+		t.Errorf("status of a missing file = %s", lastStatus)
+	}
+
+	if !f.apply() {
+		t.Fatal("apply() failed")
+	}
+	if got, _ := os.ReadFile(path); string(got) != content {
+		t.Error("unexpected downloaded content")
+	}
+	expectCheck(t, status.StatusApplied)
+
+	t.Run("content drift", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("modified"), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		expectCheck(t, status.StatusTodo)
+		if !f.apply() {
+			t.Fatal("apply() failed")
+		}
+		expectCheck(t, status.StatusApplied)
+	})
+
+	t.Run("mode drift", func(t *testing.T) {
+		if err := os.Chmod(path, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		expectCheck(t, status.StatusTodo)
+		if !f.apply() {
+			t.Fatal("apply() failed")
+		}
+		expectCheck(t, status.StatusApplied)
+	})
+
+	t.Run("owner drift", func(t *testing.T) {
+		if os.Getuid() != 0 {
+			t.Skip("changing ownership needs root")
+		}
+		// The owner parameter is only known after replacing variables
+		vars.Define("owner", "root")
+		defer vars.Define("owner", "")
+
+		expectCheck(t, status.StatusApplied)
+		if err := os.Chown(path, 65534, 65534); err != nil {
+			t.Fatal(err)
+		}
+		expectCheck(t, status.StatusTodo)
+		if !f.apply() {
+			t.Fatal("apply() failed")
+		}
+		expectCheck(t, status.StatusApplied)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		other := *f
+		other.path = filepath.Join(t.TempDir(), "other")
+		other.sha256 = strings.Repeat("0", sha256.Size*2)
+		if other.apply() {
+			t.Error("apply() succeeded with a wrong checksum")
+		}
+		if _, err := os.Stat(other.path); !errors.Is(err, fs.ErrNotExist) {
+			t.Error("file with a wrong checksum installed")
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var fileTemplateFuncs = template.FuncMap{
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+	"split": func(sep, s string) []string {
+		if s == "" {
+			return []string{}
+		}
+		return strings.Split(s, sep)
+	},
+	"lines": func(s string) []string {
+		lines := []string{}
+		for _, line := range strings.Split(s, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	},
+	"trim": strings.TrimSpace,
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+}
+
+var _ = registerFileWatcher(
+	"file template",
+	"file",
+	"Ensure a file has a content generated from a template",
+	ParamsDesc{
+		{"path", "File path", ParamTypeFilePath},
+		{"template", "Template (Go text/template)", ParamTypeText},
+		{"owner", "File owner", ParamTypeUsername},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &fileTemplate{
+			fileContent{
+				fileWatcherCmdInit(params, vars, msg),
+				params["template"].(string),
+				params["owner"].(string),
+			},
+		}
+	},
+)
+
+// fileTemplate is a fileContent whose content is rendered with text/template.
+// Variables are available in .Vars and global variables in .Facts, without
+// angle brackets.
+type fileTemplate struct {
+	fileContent
+}
+
+func (f *fileTemplate) render() (string, error) {
+	tmpl, err := template.New(f.getPath()).Funcs(fileTemplateFuncs).Option("missingkey=zero").Parse(f.content)
+	if err != nil {
+		return "", err
+	}
+
+	vars := map[string]string{}
+	for key, value := range f.vars {
+		vars[strings.TrimSuffix(strings.TrimPrefix(key, "<"), ">")] = value
+	}
+	facts := map[string]string{}
+	for _, global := range variables.Global() {
+		facts[global.Name] = global.Value
+	}
+
+	var content strings.Builder
+	if err := tmpl.Execute(&content, map[string]any{
+		"Vars":  vars,
+		"Facts": facts,
+	}); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+func (f *fileTemplate) newStatus(fstatus external.FileStatus) {
+	content, err := f.render()
+	if err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not render template for %q", f.getPath()), status.Error(err.Error()), nil)
+		return
+	}
+	f.checkContent(fstatus, content)
+}
+
+func (f *fileTemplate) apply() bool {
+	content, err := f.render()
+	if err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not render template for %q", f.getPath()), status.Error(err.Error()), nil)
+		return false
+	}
+	return f.writeContent(content)
+}
@@ -0,0 +1,308 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/assets"
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	fontsSystemDir = "/usr/local/share/fonts"
+	fontsUserDir   = ".local/share/fonts"
+)
+
+var fontExtensions = []string{".ttf", ".ttc", ".otf", ".otc", ".pfa", ".pfb", ".pcf", ".bdf", ".woff", ".woff2"}
+
+var _ = registerFileWatcher(
+	"font install",
+	"file",
+	"Install fonts and refresh the font cache",
+	ParamsDesc{
+		{"source", "Fonts (uploaded directory or local path)", ParamTypeAssetDirOrPath},
+		{"family", "Fonts directory name", ParamTypeString},
+		{"user", "User (ignored if system-wide)", ParamTypeUsername},
+		{"system", "System-wide, for all users", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &fontInstall{
+			msg:    msg,
+			vars:   vars,
+			source: params["source"].(string),
+			family: params["family"].(string),
+			user:   params["user"].(string),
+			system: params["system"].(bool),
+		}
+	},
+)
+
+type fontInstall struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	source string
+	family string
+	user   string
+	system bool
+}
+
+func isFontFile(name string) bool {
+	return slices.Contains(fontExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// fontFile is a font file to install, from an asset or a local file.
+type fontFile struct {
+	rel   string
+	sum   string
+	asset string
+	path  string
+}
+
+func (f *fontInstall) updateVariables(vars variables.Variables) (changed bool) {
+	return f.vars.Update(vars)
+}
+
+func (f *fontInstall) getPath() string {
+	family := f.vars.Replace(f.family)
+	if f.system {
+		return filepath.Join(fontsSystemDir, family)
+	}
+	return userHomePath(f.vars.Replace(f.user), filepath.Join(fontsUserDir, family))
+}
+
+// validate returns an error if the parameters cannot be applied.
+func (f *fontInstall) validate() error {
+	family := f.vars.Replace(f.family)
+	if family == "" || family == "." || family == ".." || strings.Contains(family, "/") {
+		return fmt.Errorf("%q is not a valid fonts directory name", family)
+	}
+	return nil
+}
+
+// owner returns the user owning the fonts, or "" for system-wide fonts.
+func (f *fontInstall) owner() string {
+	if f.system {
+		return ""
+	}
+	return f.vars.Replace(f.user)
+}
+
+// fonts returns the font files to install.
+func (f *fontInstall) fonts() ([]fontFile, error) {
+	source := f.vars.Replace(f.source)
+
+	if _, err := assets.Path(source); err == nil {
+		manifest, err := assets.ReadManifest(source)
+		if err != nil {
+			return nil, err
+		}
+		fonts := make([]fontFile, len(manifest.Files))
+		for i, file := range manifest.Files {
+			fonts[i] = fontFile{rel: filepath.FromSlash(file.Path), sum: file.Asset, asset: file.Asset}
+		}
+		return fonts, nil
+	}
+
+	if !filepath.IsAbs(source) {
+		return nil, fmt.Errorf("%q is neither an uploaded directory nor an absolute path", source)
+	}
+
+	finfo, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !finfo.IsDir() {
+		sum, err := sha256File(source)
+		if err != nil {
+			return nil, err
+		}
+		return []fontFile{{rel: filepath.Base(source), sum: sum, path: source}}, nil
+	}
+
+	fonts := []fontFile{}
+	err = filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if !isFontFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		sum, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		fonts = append(fonts, fontFile{rel: rel, sum: sum, path: path})
+		return nil
+	})
+	return fonts, err
+}
+
+func (f *fontInstall) newStatus(fstatus external.FileStatus) {
+	if err := f.validate(); err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return
+	}
+
+	switch fstatus {
+	case external.FileStatusFile:
+		f.msg(status.StatusFailed, fmt.Sprintf("%q is not a directory", f.getPath()), nil, nil)
+	case external.FileStatusUnknown:
+		f.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", f.getPath()), nil, nil)
+	default:
+		f.msg(f.check())
+	}
+}
+
+// fileStatus returns the status of an installed font file, or "" if it is
+// installed with the expected content.
+func (f *fontInstall) fileStatus(font fontFile) (string, error) {
+	sum, err := sha256File(filepath.Join(f.getPath(), font.rel))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "Missing", nil
+	case err != nil:
+		return "", err
+	case sum != font.sum:
+		return "Modified", nil
+	default:
+		return "", nil
+	}
+}
+
+func (f *fontInstall) check() (status.Status, string, status.Detail, variables.Variables) {
+	dest := f.getPath()
+
+	fonts, err := f.fonts()
+	if err != nil {
+		return status.StatusFailed, "Could not read fonts to install", status.Error(err.Error()), nil
+	}
+
+	families, err := external.FcList(f.owner())
+	if err != nil {
+		return status.StatusFailed, "Could not list installed fonts", status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"File", "Status", "Family"},
+	}
+
+	var todo, uncached int
+	for _, font := range fonts {
+		fileStatus, err := f.fileStatus(font)
+		if err != nil {
+			return status.StatusFailed, fmt.Sprintf("Could not read %q", filepath.Join(dest, font.rel)), status.Error(err.Error()), nil
+		}
+
+		rowStatus := status.StatusApplied
+		if fileStatus != "" {
+			rowStatus = status.StatusTodo
+			todo++
+		} else {
+			fileStatus = "Installed"
+			// Other uploaded files, like licenses, are not in the cache
+			if _, ok := families[filepath.Join(dest, font.rel)]; !ok && isFontFile(font.rel) {
+				rowStatus, fileStatus = status.StatusTodo, "Not in font cache"
+				uncached++
+			}
+		}
+
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: filepath.ToSlash(font.rel)},
+			status.TableCell{Status: rowStatus, Content: fileStatus},
+			status.TableCell{Status: status.StatusNone, Content: families[filepath.Join(dest, font.rel)]},
+		)
+	}
+
+	switch {
+	case todo > 0:
+		return status.StatusTodo, fmt.Sprintf("Need to install %d fonts into %q", todo, dest), &result, nil
+	case uncached > 0:
+		return status.StatusTodo, fmt.Sprintf("Need to refresh the font cache for %q", dest), &result, nil
+	default:
+		return status.StatusApplied, fmt.Sprintf("%d fonts installed into %q", len(fonts), dest), &result, nil
+	}
+}
+
+func (f *fontInstall) apply() bool {
+	if err := f.validate(); err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	dest := f.getPath()
+	owner := f.owner()
+
+	fonts, err := f.fonts()
+	if err != nil {
+		f.msg(status.StatusFailed, "Could not read fonts to install", status.Error(err.Error()), nil)
+		return false
+	}
+
+	f.msg(status.StatusRunning, fmt.Sprintf("Installing fonts into %q", dest), nil, nil)
+
+	for _, font := range fonts {
+		fullPath := filepath.Join(dest, font.rel)
+
+		fileStatus, err := f.fileStatus(font)
+		if err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", fullPath), status.Error(err.Error()), nil)
+			return false
+		}
+		if fileStatus == "" {
+			continue
+		}
+
+		if err := mkdirAllOwned(filepath.Dir(fullPath), 0o755, owner); err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", filepath.Dir(fullPath)), status.Error(err.Error()), nil)
+			return false
+		}
+
+		if font.asset != "" {
+			err = installAsset(font.asset, fullPath, 0o644, owner)
+		} else {
+			err = f.installLocal(font.path, fullPath, owner)
+		}
+		if err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not copy font to %q", fullPath), status.Error(err.Error()), nil)
+			return false
+		}
+	}
+
+	return external.FcCache(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = fmt.Sprintf("Refreshing font cache for %q", dest)
+				case status.StatusApplied:
+					info = fmt.Sprintf("%d fonts installed into %q", len(fonts), dest)
+				case status.StatusFailed:
+					info = fmt.Sprintf("Failed refreshing font cache for %q", dest)
+				}
+			}
+			f.msg(s, info, detail, nil)
+		},
+		owner, dest,
+	)
+}
+
+func (f *fontInstall) installLocal(src, dst, owner string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	return installFile(srcFile, dst, 0o644, owner)
+}
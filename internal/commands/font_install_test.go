@@ -0,0 +1,23 @@
+package commands
+
+import "testing"
+
+func TestFontInstallValidate(t *testing.T) {
+	for _, tc := range []struct {
+		family string
+		valid  bool
+	}{
+		{"corporate", true},
+		{"Corporate Sans", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../../bin", false},
+		{"corporate/sans", false},
+	} {
+		font := fontInstall{family: tc.family}
+		if err := font.validate(); (err == nil) != tc.valid {
+			t.Errorf("validate() for %q returned %v", tc.family, err)
+		}
+	}
+}
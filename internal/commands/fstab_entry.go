@@ -0,0 +1,362 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const fstabPath = "/etc/fstab"
+
+var _ = registerFileWatcher(
+	"fstab entry",
+	"drive",
+	"Ensure a mount point is declared in /etc/fstab",
+	ParamsDesc{
+		{"device", "Device or UUID=...", ParamTypeString},
+		{"mountpoint", "Mount point", ParamTypeFilePath},
+		{"fstype", "Filesystem type", ParamTypeString},
+		{"options", "Mount options", ParamTypeString},
+		{"dump", "Dump", ParamTypeString},
+		{"pass", "Pass", ParamTypeString},
+		{"mount", "Mount after change", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &fstabEntry{
+			msg:        msg,
+			vars:       vars,
+			device:     params["device"].(string),
+			mountpoint: params["mountpoint"].(string),
+			fstype:     params["fstype"].(string),
+			options:    params["options"].(string),
+			dump:       params["dump"].(string),
+			pass:       params["pass"].(string),
+			mount:      params["mount"].(bool),
+		}
+	},
+)
+
+type fstabFields struct {
+	device     string
+	mountpoint string
+	fstype     string
+	options    string
+	dump       string
+	pass       string
+}
+
+func (f fstabFields) line() string {
+	return strings.Join([]string{
+		external.EscapeMountField(f.device),
+		external.EscapeMountField(f.mountpoint),
+		f.fstype,
+		f.options,
+		f.dump,
+		f.pass,
+	}, "\t")
+}
+
+// parseFstabLine parses a line from /etc/fstab, returning false if it is not
+// an entry.
+func parseFstabLine(line string) (fstabFields, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return fstabFields{}, false
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return fstabFields{}, false
+	}
+	for len(fields) < 6 {
+		fields = append(fields, "0")
+	}
+	return fstabFields{
+		device:     external.UnescapeMountField(fields[0]),
+		mountpoint: filepath.Clean(external.UnescapeMountField(fields[1])),
+		fstype:     fields[2],
+		options:    normalizeMountOptions(fields[3]),
+		dump:       fields[4],
+		pass:       fields[5],
+	}, true
+}
+
+// normalizeMountOptions sorts mount options and removes duplicates, so that
+// options lists can be compared.
+func normalizeMountOptions(options string) string {
+	opts := []string{}
+	for _, opt := range strings.Split(options, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			opts = append(opts, opt)
+		}
+	}
+	if len(opts) == 0 {
+		return "defaults"
+	}
+	slices.Sort(opts)
+	return strings.Join(slices.Compact(opts), ",")
+}
+
+type fstabEntry struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	device     string
+	mountpoint string
+	fstype     string
+	options    string
+	dump       string
+	pass       string
+	mount      bool
+}
+
+func (f *fstabEntry) updateVariables(vars variables.Variables) (changed bool) {
+	return f.vars.Update(vars)
+}
+
+func (f *fstabEntry) getPath() string {
+	return fstabPath
+}
+
+func (f *fstabEntry) expected() fstabFields {
+	dump := f.vars.Replace(f.dump)
+	if dump == "" {
+		dump = "0"
+	}
+	pass := f.vars.Replace(f.pass)
+	if pass == "" {
+		pass = "0"
+	}
+	return fstabFields{
+		device:     f.vars.Replace(f.device),
+		mountpoint: filepath.Clean(f.vars.Replace(f.mountpoint)),
+		fstype:     f.vars.Replace(f.fstype),
+		options:    normalizeMountOptions(f.vars.Replace(f.options)),
+		dump:       dump,
+		pass:       pass,
+	}
+}
+
+// validate returns an error if a parameter cannot be written as an fstab field.
+// Whitespace in the device and the mount point is escaped, it is only refused
+// in the other fields.
+func (f *fstabEntry) validate() error {
+	for _, field := range []struct {
+		name     string
+		value    string
+		required bool
+		escaped  bool
+	}{
+		{"device", f.vars.Replace(f.device), true, true},
+		{"mount point", f.vars.Replace(f.mountpoint), true, true},
+		{"filesystem type", f.vars.Replace(f.fstype), true, false},
+		{"options", f.vars.Replace(f.options), false, false},
+		{"dump", f.vars.Replace(f.dump), false, false},
+		{"pass", f.vars.Replace(f.pass), false, false},
+	} {
+		if field.required && strings.TrimSpace(field.value) == "" {
+			return fmt.Errorf("%s must not be empty", field.name)
+		}
+		if !field.escaped && strings.IndexFunc(field.value, unicode.IsSpace) != -1 {
+			return fmt.Errorf("%s %q must not contain whitespace", field.name, field.value)
+		}
+	}
+	return nil
+}
+
+// current returns the lines of /etc/fstab, and the index and content of the
+// line for the required mount point, or -1 if there is none.
+func (f *fstabEntry) current() ([]string, int, fstabFields, error) {
+	content, err := os.ReadFile(fstabPath)
+	if err != nil {
+		return nil, -1, fstabFields{}, err
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	mountpoint := f.expected().mountpoint
+	for i, line := range lines {
+		if entry, ok := parseFstabLine(line); ok && entry.mountpoint == mountpoint {
+			return lines, i, entry, nil
+		}
+	}
+	return lines, -1, fstabFields{}, nil
+}
+
+func (f *fstabEntry) newStatus(fstatus external.FileStatus) {
+	switch fstatus {
+	case external.FileStatusFile:
+		f.msg(f.check())
+	case external.FileStatusDirectory:
+		f.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", fstabPath), nil, nil)
+	case external.FileStatusNotFound:
+		f.msg(status.StatusFailed, fmt.Sprintf("File %q not found", fstabPath), nil, nil)
+	case external.FileStatusUnknown:
+		f.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", fstabPath), nil, nil)
+	}
+}
+
+func (f *fstabEntry) check() (status.Status, string, status.Detail, variables.Variables) {
+	if err := f.validate(); err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	expected := f.expected()
+
+	_, index, current, err := f.current()
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not read %q", fstabPath), status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"Field", "Current", "Expected"},
+	}
+
+	todo := index == -1
+	for _, field := range []struct {
+		name              string
+		current, expected string
+	}{
+		{"Device", current.device, expected.device},
+		{"Filesystem type", current.fstype, expected.fstype},
+		{"Options", current.options, expected.options},
+		{"Dump", current.dump, expected.dump},
+		{"Pass", current.pass, expected.pass},
+	} {
+		fieldStatus := status.StatusApplied
+		if field.current != field.expected {
+			fieldStatus = status.StatusTodo
+			todo = true
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: field.name},
+			status.TableCell{Status: fieldStatus, Content: field.current},
+			status.TableCell{Status: status.StatusNone, Content: field.expected},
+		)
+	}
+
+	mounts, err := external.MountPoints()
+	if err != nil {
+		return status.StatusFailed, "Could not get active mount points", status.Error(err.Error()), nil
+	}
+	mounted, isMounted := mounts[expected.mountpoint]
+	mountedStatus, mountedContent := status.StatusApplied, "Mounted from "+mounted.Source
+	if !isMounted {
+		mountedStatus, mountedContent = status.StatusNone, "Not mounted"
+		if f.mount {
+			mountedStatus = status.StatusTodo
+		}
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "Active"},
+		status.TableCell{Status: mountedStatus, Content: mountedContent},
+		status.TableCell{Status: status.StatusNone, Content: ""},
+	)
+
+	switch {
+	case index == -1:
+		return status.StatusTodo, fmt.Sprintf("Need to add %q to %q", expected.mountpoint, fstabPath), &result, nil
+	case todo:
+		return status.StatusTodo, fmt.Sprintf("Need to change %q in %q", expected.mountpoint, fstabPath), &result, nil
+	case mountedStatus == status.StatusTodo:
+		return status.StatusTodo, fmt.Sprintf("Need to mount %q", expected.mountpoint), &result, nil
+	case isMounted:
+		return status.StatusApplied, fmt.Sprintf("%q is declared and mounted", expected.mountpoint), &result, nil
+	default:
+		return status.StatusApplied, fmt.Sprintf("%q is declared", expected.mountpoint), &result, nil
+	}
+}
+
+func (f *fstabEntry) apply() bool {
+	if err := f.validate(); err != nil {
+		f.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	expected := f.expected()
+
+	lines, index, current, err := f.current()
+	if err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", fstabPath), status.Error(err.Error()), nil)
+		return false
+	}
+
+	changed := index == -1 || current != expected
+	if changed {
+		if index == -1 {
+			lines = append(lines, expected.line())
+		} else {
+			lines[index] = expected.line()
+		}
+
+		tmpPath := fstabPath + ".keepakonf"
+		if err := os.WriteFile(tmpPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", tmpPath), status.Error(err.Error()), nil)
+			return false
+		}
+		if err := os.Rename(tmpPath, fstabPath); err != nil {
+			os.Remove(tmpPath)
+			f.msg(status.StatusFailed, fmt.Sprintf("Could not replace %q", fstabPath), status.Error(err.Error()), nil)
+			return false
+		}
+
+		// systemd generates mount units from /etc/fstab
+		if !external.Systemctl(stepReceiver(f.msg, "Reloading systemd"), "daemon-reload") {
+			return false
+		}
+	}
+
+	if err := os.MkdirAll(expected.mountpoint, 0o755); err != nil {
+		f.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", expected.mountpoint), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if f.mount {
+		mounts, err := external.MountPoints()
+		if err != nil {
+			f.msg(status.StatusFailed, "Could not get active mount points", status.Error(err.Error()), nil)
+			return false
+		}
+		args := []string{expected.mountpoint}
+		info := "Mounting " + expected.mountpoint
+		if _, ok := mounts[expected.mountpoint]; ok {
+			if !changed {
+				f.msg(status.StatusApplied, fmt.Sprintf("%q is declared and mounted", expected.mountpoint), nil, nil)
+				return true
+			}
+			if index == -1 || current.device != expected.device || current.fstype != expected.fstype {
+				// A remount does not change the mounted device
+				if !external.Umount(stepReceiver(f.msg, "Unmounting "+expected.mountpoint), expected.mountpoint) {
+					return false
+				}
+			} else {
+				args = []string{"-o", "remount", expected.mountpoint}
+				info = "Remounting " + expected.mountpoint
+			}
+		}
+
+		return external.Mount(
+			func(s status.Status, msg string, detail status.Detail) {
+				if msg == "" {
+					switch s {
+					case status.StatusRunning:
+						msg = info
+					case status.StatusApplied:
+						msg = fmt.Sprintf("%q is declared and mounted", expected.mountpoint)
+					case status.StatusFailed:
+						msg = "Failed mounting " + expected.mountpoint
+					}
+				}
+				f.msg(s, msg, detail, nil)
+			},
+			args...,
+		)
+	}
+
+	f.msg(status.StatusApplied, fmt.Sprintf("%q is declared in %q", expected.mountpoint, fstabPath), nil, nil)
+	return true
+}
@@ -0,0 +1,68 @@
+package commands
+
+import "testing"
+
+func TestParseFstabLine(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected fstabFields
+		ok       bool
+	}{
+		{"", fstabFields{}, false},
+		{"# /home was on /dev/sda2", fstabFields{}, false},
+		{"/dev/sda1 /boot", fstabFields{}, false},
+		{
+			"UUID=1234 / ext4 errors=remount-ro 0 1",
+			fstabFields{"UUID=1234", "/", "ext4", "errors=remount-ro", "0", "1"},
+			true,
+		},
+		{
+			"  /dev/sdb1\t/mnt/data/  ext4  noatime,defaults,noatime",
+			fstabFields{"/dev/sdb1", "/mnt/data", "ext4", "defaults,noatime", "0", "0"},
+			true,
+		},
+		{
+			`//server/share\040name /mnt/my\040share cifs , 0 0`,
+			fstabFields{"//server/share name", "/mnt/my share", "cifs", "defaults", "0", "0"},
+			true,
+		},
+	} {
+		entry, ok := parseFstabLine(tc.line)
+		if ok != tc.ok || entry != tc.expected {
+			t.Errorf("parseFstabLine(%q) = %+v, %t, expected %+v, %t", tc.line, entry, ok, tc.expected, tc.ok)
+		}
+	}
+}
+
+func TestFstabFieldsLine(t *testing.T) {
+	fields := fstabFields{"/dev/sdb1", "/mnt/my share", "ext4", "defaults", "0", "2"}
+	line := fields.line()
+	if line != "/dev/sdb1\t/mnt/my\\040share\text4\tdefaults\t0\t2" {
+		t.Errorf("unexpected line %q", line)
+	}
+	if parsed, ok := parseFstabLine(line); !ok || parsed != fields {
+		t.Errorf("parsing %q gives %+v, %t", line, parsed, ok)
+	}
+}
+
+func TestFstabEntryValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		entry fstabEntry
+		valid bool
+	}{
+		{"valid", fstabEntry{device: "UUID=1234", mountpoint: "/mnt", fstype: "ext4"}, true},
+		{"empty device", fstabEntry{mountpoint: "/mnt", fstype: "ext4"}, false},
+		{"empty mount point", fstabEntry{device: "/dev/sdb1", fstype: "ext4"}, false},
+		{"empty type", fstabEntry{device: "/dev/sdb1", mountpoint: "/mnt"}, false},
+		{"space in device", fstabEntry{device: "//nas/my share", mountpoint: "/mnt", fstype: "cifs"}, true},
+		{"tab in mount point", fstabEntry{device: "/dev/sdb1", mountpoint: "/mnt\tx", fstype: "ext4"}, true},
+		{"blank mount point", fstabEntry{device: "/dev/sdb1", mountpoint: " ", fstype: "ext4"}, false},
+		{"space in type", fstabEntry{device: "/dev/sdb1", mountpoint: "/mnt", fstype: "ext 4"}, false},
+		{"space in options", fstabEntry{device: "/dev/sdb1", mountpoint: "/mnt", fstype: "ext4", options: "ro, noatime"}, false},
+	} {
+		if err := tc.entry.validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: validate() returned %v", tc.name, err)
+		}
+	}
+}
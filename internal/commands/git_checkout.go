@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = registerFileWatcher(
+	"git checkout",
+	"git",
+	"Checkout a git repository",
+	ParamsDesc{
+		{"url", "Repository URL", ParamTypeString},
+		{"destination", "Destination directory", ParamTypeFilePath},
+		{"revision", "Branch, tag or commit", ParamTypeString},
+		{"owner", "Repository owner", ParamTypeUsername},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &gitCheckout{
+			msg:         msg,
+			vars:        vars,
+			url:         params["url"].(string),
+			destination: params["destination"].(string),
+			revision:    params["revision"].(string),
+			owner:       params["owner"].(string),
+		}
+	},
+)
+
+type gitCheckout struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	url         string
+	destination string
+	revision    string
+	owner       string
+}
+
+func (g *gitCheckout) updateVariables(vars variables.Variables) (changed bool) {
+	return g.vars.Update(vars)
+}
+
+// getPath returns the path to the git index, which changes on every checkout,
+// commit or reset.
+func (g *gitCheckout) getPath() string {
+	return filepath.Join(g.vars.Replace(g.destination), ".git", "index")
+}
+
+func (g *gitCheckout) newStatus(fstatus external.FileStatus) {
+	if fstatus == external.FileStatusUnknown {
+		g.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", g.vars.Replace(g.destination)), nil, nil)
+		return
+	}
+	g.msg(g.check())
+}
+
+// resolve returns the commit hash matching ref in the local repository.
+func (g *gitCheckout) resolve(ref string) (string, error) {
+	return external.GitOutput(
+		g.vars.Replace(g.owner), g.vars.Replace(g.destination),
+		"rev-parse", "--verify", "--quiet", ref+"^{commit}",
+	)
+}
+
+// expectedRef returns the local reference for the required revision, and
+// whether it is a remote branch.
+func (g *gitCheckout) expectedRef() (ref string, isBranch bool) {
+	revision := g.vars.Replace(g.revision)
+	if revision == "" {
+		return "refs/remotes/origin/HEAD", true
+	}
+	if _, err := g.resolve("refs/remotes/origin/" + revision); err == nil {
+		return "refs/remotes/origin/" + revision, true
+	}
+	return revision, false
+}
+
+func (g *gitCheckout) check() (status.Status, string, status.Detail, variables.Variables) {
+	dest := g.vars.Replace(g.destination)
+	url := g.vars.Replace(g.url)
+	owner := g.vars.Replace(g.owner)
+
+	finfo, err := os.Stat(filepath.Join(dest, ".git"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return status.StatusTodo, fmt.Sprintf("Need to clone %q into %q", url, dest), nil, nil
+		}
+		return status.StatusFailed, fmt.Sprintf("Could not check status of %q", dest), status.Error(err.Error()), nil
+	}
+	if !finfo.IsDir() {
+		return status.StatusFailed, fmt.Sprintf("%q is not a git repository", dest), nil, nil
+	}
+
+	result := status.Table{
+		Header: []string{"", "Current", "Expected"},
+	}
+
+	var todo []string
+
+	currentURL, _ := external.GitOutput(owner, dest, "config", "--get", "remote.origin.url")
+	urlStatus := status.StatusApplied
+	if currentURL != url {
+		urlStatus = status.StatusTodo
+		todo = append(todo, "change origin URL")
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "Origin"},
+		status.TableCell{Status: urlStatus, Content: currentURL},
+		status.TableCell{Status: status.StatusNone, Content: url},
+	)
+
+	ref, _ := g.expectedRef()
+	currentCommit, err := g.resolve("HEAD")
+	if err != nil {
+		currentCommit = "None"
+	}
+	expectedCommit, err := g.resolve(ref)
+	commitStatus := status.StatusApplied
+	switch {
+	case err != nil:
+		expectedCommit = "Unknown, need to fetch"
+		commitStatus = status.StatusTodo
+		todo = append(todo, "fetch "+g.vars.Replace(g.revision))
+	case currentCommit != expectedCommit:
+		commitStatus = status.StatusTodo
+		todo = append(todo, "checkout "+expectedCommit)
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "Commit"},
+		status.TableCell{Status: commitStatus, Content: currentCommit},
+		status.TableCell{Status: status.StatusNone, Content: expectedCommit},
+	)
+
+	changes, err := external.GitOutput(owner, dest, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not get status of %q", dest), status.Error(err.Error()), nil
+	}
+	treeStatus := status.StatusApplied
+	treeContent := "Clean"
+	if changes != "" {
+		treeStatus = status.StatusTodo
+		treeContent = fmt.Sprintf("%d modified files", len(strings.Split(changes, "\n")))
+		todo = append(todo, "discard local changes")
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "Working tree"},
+		status.TableCell{Status: treeStatus, Content: treeContent},
+		status.TableCell{Status: status.StatusNone, Content: "Clean"},
+	)
+
+	if len(todo) > 0 {
+		return status.StatusTodo, fmt.Sprintf("Need to %s in %q", strings.Join(todo, ", "), dest), &result, nil
+	}
+	return status.StatusApplied, fmt.Sprintf("%q is at the required revision", dest), &result, nil
+}
+
+func (g *gitCheckout) step(action string) func(status.Status, string, status.Detail) {
+	return stepReceiver(g.msg, action)
+}
+
+func (g *gitCheckout) apply() bool {
+	dest := g.vars.Replace(g.destination)
+	url := g.vars.Replace(g.url)
+	owner := g.vars.Replace(g.owner)
+	revision := g.vars.Replace(g.revision)
+
+	if _, err := os.Stat(filepath.Join(dest, ".git")); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			g.msg(status.StatusFailed, fmt.Sprintf("Could not check status of %q", dest), status.Error(err.Error()), nil)
+			return false
+		}
+
+		if err := os.MkdirAll(dest, 0o755); err != nil {
+			g.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", dest), status.Error(err.Error()), nil)
+			return false
+		}
+		if owner != "" {
+			userData, err := external.GetUser(owner)
+			if err != nil {
+				g.msg(status.StatusFailed, fmt.Sprintf("Could not get user information for %q", owner), status.Error(err.Error()), nil)
+				return false
+			}
+			if err := os.Chown(dest, userData.ID, userData.GID); err != nil {
+				g.msg(status.StatusFailed, fmt.Sprintf("Could not change %q ownership to %q", dest, owner), status.Error(err.Error()), nil)
+				return false
+			}
+		}
+
+		if !external.Git(g.step("Cloning "+url), owner, dest, "clone", "--no-checkout", url, ".") {
+			return false
+		}
+	} else {
+		if currentURL, _ := external.GitOutput(owner, dest, "config", "--get", "remote.origin.url"); currentURL != url {
+			if !external.Git(g.step("Changing origin URL to "+url), owner, dest, "remote", "set-url", "origin", url) {
+				return false
+			}
+		}
+		if !external.Git(g.step("Fetching "+url), owner, dest, "fetch", "--tags", "--force", "origin") {
+			return false
+		}
+		if revision == "" {
+			if !external.Git(g.step("Getting default branch from "+url), owner, dest, "remote", "set-head", "origin", "--auto") {
+				return false
+			}
+		}
+	}
+
+	ref, isBranch := g.expectedRef()
+	var args []string
+	switch {
+	case isBranch && revision == "":
+		remoteHead, err := external.GitOutput(owner, dest, "symbolic-ref", "--short", ref)
+		if err != nil {
+			g.msg(status.StatusFailed, fmt.Sprintf("Could not get default branch of %q", url), status.Error(err.Error()), nil)
+			return false
+		}
+		args = []string{"checkout", "--force", "-B", strings.TrimPrefix(remoteHead, "origin/"), ref}
+	case isBranch:
+		args = []string{"checkout", "--force", "-B", revision, ref}
+	default:
+		args = []string{"checkout", "--force", "--detach", revision}
+	}
+
+	label := revision
+	if label == "" {
+		label = "default branch"
+	}
+	if !external.Git(g.step("Checking out "+label), owner, dest, args...) {
+		return false
+	}
+
+	st, info, detail, _ := g.check()
+	if st != status.StatusApplied {
+		g.msg(status.StatusFailed, info, detail, nil)
+		return false
+	}
+
+	g.msg(status.StatusApplied, fmt.Sprintf("Checked out %q into %q", url, dest), detail, nil)
+	return true
+}
@@ -0,0 +1,323 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	modulesLoadDir = "/etc/modules-load.d"
+	modprobeDir    = "/etc/modprobe.d"
+)
+
+// The module name is part of the drop-in file names
+var kernelModuleNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var _ = register(
+	"kernel module",
+	"module",
+	"Load or blacklist a kernel module",
+	ParamsDesc{
+		{"module", "Module name", ParamTypeString},
+		{"load", "Load at boot", ParamTypeBool},
+		{"blacklist", "Blacklist", ParamTypeBool},
+		{"options", "Module options", ParamTypeString},
+		{"now", "Apply immediately (modprobe, update-initramfs)", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &kernelModule{
+			msg:       msg,
+			vars:      vars,
+			module:    params["module"].(string),
+			load:      params["load"].(bool),
+			blacklist: params["blacklist"].(bool),
+			options:   params["options"].(string),
+			now:       params["now"].(bool),
+		}
+	},
+)
+
+type kernelModule struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	module    string
+	load      bool
+	blacklist bool
+	options   string
+	now       bool
+
+	applying  atomic.Bool
+	loadClose func()
+	confClose func()
+	closeChan chan struct{}
+}
+
+func (k *kernelModule) loadPath() string {
+	return filepath.Join(modulesLoadDir, "keepakonf-"+k.vars.Replace(k.module)+".conf")
+}
+
+func (k *kernelModule) confPath() string {
+	return filepath.Join(modprobeDir, "keepakonf-"+k.vars.Replace(k.module)+".conf")
+}
+
+// validate returns an error if the parameters cannot be applied.
+func (k *kernelModule) validate() error {
+	module := k.vars.Replace(k.module)
+	if !kernelModuleNameRe.MatchString(module) {
+		return fmt.Errorf("%q is not a valid module name", module)
+	}
+	if k.load && k.blacklist {
+		return fmt.Errorf("module %s cannot be both loaded and blacklisted", module)
+	}
+	return nil
+}
+
+func (k *kernelModule) loadContent() string {
+	if !k.load {
+		return ""
+	}
+	return "# Placed by Keepakonf\n" + k.vars.Replace(k.module) + "\n"
+}
+
+func (k *kernelModule) confContent() string {
+	module := k.vars.Replace(k.module)
+	options := k.vars.Replace(k.options)
+	if !k.blacklist && options == "" {
+		return ""
+	}
+
+	var content strings.Builder
+	content.WriteString("# Placed by Keepakonf\n")
+	if k.blacklist {
+		content.WriteString("blacklist " + module + "\n")
+	}
+	if options != "" {
+		content.WriteString("options " + module + " " + options + "\n")
+	}
+	return content.String()
+}
+
+func (k *kernelModule) UpdateVariables(vars variables.Variables) {
+	if k.vars.Update(vars) {
+		k.Stop()
+		k.Watch()
+	}
+}
+
+func (k *kernelModule) Watch() {
+	loadChan, loadClose := external.WatchFile(k.loadPath())
+	k.loadClose = loadClose
+
+	confChan, confClose := external.WatchFile(k.confPath())
+	k.confClose = confClose
+
+	closeChan := make(chan struct{})
+	k.closeChan = closeChan
+
+	go func() {
+		for {
+			select {
+			case <-loadChan:
+			case <-confChan:
+			case <-closeChan:
+				return
+			}
+			if k.applying.Load() {
+				// No update if it is currently applying
+				continue
+			}
+			k.msg(k.check())
+		}
+	}()
+}
+
+func (k *kernelModule) Stop() {
+	if k.loadClose != nil {
+		k.loadClose()
+	}
+	if k.confClose != nil {
+		k.confClose()
+	}
+	if k.closeChan != nil {
+		close(k.closeChan)
+		k.closeChan = nil
+	}
+}
+
+func (k *kernelModule) isLoaded() (bool, error) {
+	modules, err := external.LoadedKernelModules()
+	if err != nil {
+		return false, err
+	}
+	_, loaded := modules[external.KernelModuleName(k.vars.Replace(k.module))]
+	return loaded, nil
+}
+
+func (k *kernelModule) check() (status.Status, string, status.Detail, variables.Variables) {
+	if err := k.validate(); err != nil {
+		return status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil
+	}
+
+	module := k.vars.Replace(k.module)
+
+	result := status.Table{
+		Header: []string{"", "Current", "Expected"},
+	}
+
+	var todo bool
+	for _, file := range []struct {
+		path     string
+		expected string
+	}{
+		{k.loadPath(), k.loadContent()},
+		{k.confPath(), k.confContent()},
+	} {
+		current, err := readOptionalFile(file.path)
+		if err != nil {
+			return status.StatusFailed, fmt.Sprintf("Could not read %q", file.path), status.Error(err.Error()), nil
+		}
+		fileStatus := status.StatusApplied
+		if current != file.expected {
+			fileStatus = status.StatusTodo
+			todo = true
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: file.path},
+			status.TableCell{Status: fileStatus, Content: kernelModuleFileSummary(current)},
+			status.TableCell{Status: status.StatusNone, Content: kernelModuleFileSummary(file.expected)},
+		)
+	}
+
+	loaded, err := k.isLoaded()
+	if err != nil {
+		return status.StatusFailed, "Could not read loaded kernel modules", status.Error(err.Error()), nil
+	}
+	loadedStatus, loadedContent := status.StatusNone, "Not loaded"
+	if loaded {
+		loadedContent = "Loaded"
+	}
+	expectedLoaded := "Any"
+	switch {
+	case k.load:
+		expectedLoaded = "Loaded"
+	case k.blacklist:
+		expectedLoaded = "Not loaded"
+	}
+	if expectedLoaded != "Any" {
+		loadedStatus = status.StatusApplied
+		if loadedContent != expectedLoaded {
+			loadedStatus = status.StatusNone
+			if k.now {
+				loadedStatus = status.StatusTodo
+				todo = true
+			}
+		}
+	}
+	result.AppendRow(
+		status.TableCell{Status: status.StatusNone, Content: "Module " + module},
+		status.TableCell{Status: loadedStatus, Content: loadedContent},
+		status.TableCell{Status: status.StatusNone, Content: expectedLoaded},
+	)
+
+	if todo {
+		return status.StatusTodo, "Need to configure kernel module " + module, &result, nil
+	}
+	return status.StatusApplied, "Kernel module " + module + " configured", &result, nil
+}
+
+// kernelModuleFileSummary returns the configuration lines of a drop-in file.
+func kernelModuleFileSummary(content string) string {
+	lines := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if line != "" && line[0] != '#' {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "None"
+	}
+	return strings.Join(lines, "; ")
+}
+
+func (k *kernelModule) Apply() bool {
+	module := k.vars.Replace(k.module)
+
+	k.applying.Store(true)
+	defer k.applying.Store(false)
+
+	if err := k.validate(); err != nil {
+		k.msg(status.StatusFailed, "Wrong parameter", status.Error(err.Error()), nil)
+		return false
+	}
+
+	var confChanged bool
+	for _, file := range []struct {
+		path     string
+		expected string
+	}{
+		{k.loadPath(), k.loadContent()},
+		{k.confPath(), k.confContent()},
+	} {
+		current, err := readOptionalFile(file.path)
+		if err != nil {
+			k.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", file.path), status.Error(err.Error()), nil)
+			return false
+		}
+		if current == file.expected {
+			continue
+		}
+		if err := writeOrRemoveFile(file.path, file.expected, 0o644); err != nil {
+			k.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", file.path), status.Error(err.Error()), nil)
+			return false
+		}
+		if file.path == k.confPath() {
+			confChanged = true
+		}
+	}
+
+	// Keep the output of the last command for the final message
+	var output status.Detail
+	step := func(action string) func(status.Status, string, status.Detail) {
+		receiver := stepReceiver(k.msg, action)
+		return func(s status.Status, info string, detail status.Detail) {
+			output = detail
+			receiver(s, info, detail)
+		}
+	}
+
+	if k.now {
+		loaded, err := k.isLoaded()
+		if err != nil {
+			k.msg(status.StatusFailed, "Could not read loaded kernel modules", status.Error(err.Error()), nil)
+			return false
+		}
+
+		switch {
+		case k.load && !loaded:
+			if !external.Modprobe(step("Loading module "+module), module) {
+				return false
+			}
+		case k.blacklist && loaded:
+			if !external.Modprobe(step("Unloading module "+module), "--remove", module) {
+				return false
+			}
+		}
+
+		if confChanged {
+			if !external.UpdateInitramfs(step("Updating initramfs")) {
+				return false
+			}
+		}
+	}
+
+	k.msg(status.StatusApplied, "Kernel module "+module+" configured", output, nil)
+	return true
+}
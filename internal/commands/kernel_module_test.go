@@ -0,0 +1,27 @@
+package commands
+
+import "testing"
+
+func TestKernelModuleValidate(t *testing.T) {
+	for _, tc := range []struct {
+		module    string
+		load      bool
+		blacklist bool
+		valid     bool
+	}{
+		{"pcspkr", false, true, true},
+		{"v4l2loopback", true, false, true},
+		{"snd-hda-intel", false, false, true},
+		{"", false, true, false},
+		{"../modules", true, false, false},
+		{"sub/module", true, false, false},
+		{"..", false, true, false},
+		{"pcspkr nouveau", false, true, false},
+		{"nouveau", true, true, false},
+	} {
+		module := kernelModule{module: tc.module, load: tc.load, blacklist: tc.blacklist}
+		if err := module.validate(); (err == nil) != tc.valid {
+			t.Errorf("validate() for %q returned %v", tc.module, err)
+		}
+	}
+}
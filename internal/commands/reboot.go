@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+var _ = register(
+	"reboot",
+	"reboot",
+	"Reboot when packages require it",
+	ParamsDesc{
+		{"when", "Reboot time (eg. +5 or 02:00, empty to reboot immediately)", ParamTypeOptString},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &reboot{
+			msg:  msg,
+			vars: vars,
+			when: params["when"].(string),
+		}
+	},
+)
+
+type reboot struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	when string
+
+	close func()
+}
+
+func (r *reboot) UpdateVariables(vars variables.Variables) {
+	r.vars.Update(vars)
+}
+
+func (r *reboot) Watch() {
+	states, close := external.RebootRequiredListen()
+	r.close = close
+
+	go func() {
+		for state := range states {
+			r.update(state)
+		}
+	}()
+}
+
+func (r *reboot) update(state external.RebootRequired) {
+	if !state.Required {
+		r.msg(status.StatusApplied, "No reboot required", nil, nil)
+		return
+	}
+
+	if len(state.Packages) == 0 {
+		r.msg(status.StatusTodo, "Reboot required", nil, nil)
+		return
+	}
+
+	table := status.Table{
+		Header: []string{"Package requiring a reboot"},
+	}
+	for _, pkg := range state.Packages {
+		table.AppendRow(status.TableCell{Status: status.StatusTodo, Content: pkg})
+	}
+	r.msg(status.StatusTodo, "Reboot required by "+strings.Join(state.Packages, ", "), &table, nil)
+}
+
+func (r *reboot) Stop() {
+	if r.close != nil {
+		r.close()
+	}
+}
+
+func (r *reboot) Apply() bool {
+	when := r.vars.Replace(r.when)
+
+	if when == "" {
+		return external.Systemctl(
+			func(s status.Status, info string, detail status.Detail) {
+				if info == "" {
+					switch s {
+					case status.StatusRunning, status.StatusApplied:
+						info = "Rebooting"
+					case status.StatusFailed:
+						info = "Failed rebooting"
+					}
+				}
+				r.msg(s, info, detail, nil)
+			},
+			"reboot",
+		)
+	}
+
+	return external.Shutdown(
+		func(s status.Status, info string, detail status.Detail) {
+			if info == "" {
+				switch s {
+				case status.StatusRunning:
+					info = "Scheduling reboot at " + when
+				case status.StatusApplied:
+					info = "Reboot scheduled at " + when
+				case status.StatusFailed:
+					info = "Failed scheduling reboot at " + when
+				}
+			}
+			r.msg(s, info, detail, nil)
+		},
+		"--reboot", when,
+	)
+}
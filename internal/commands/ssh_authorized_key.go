@@ -0,0 +1,390 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	sshDirMode            fs.FileMode = 0o700
+	sshAuthorizedKeysMode fs.FileMode = 0o600
+)
+
+var _ = register(
+	"ssh authorized key",
+	"key",
+	"Authorize SSH keys for a user",
+	ParamsDesc{
+		{"user", "User", ParamTypeUsername},
+		{"keys", "Public keys", ParamTypeStringArray},
+		{"exclusive", "Remove other keys", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &sshAuthorizedKey{
+			msg:       msg,
+			vars:      vars,
+			user:      params["user"].(string),
+			keys:      params["keys"].([]string),
+			exclusive: params["exclusive"].(bool),
+		}
+	},
+)
+
+type sshKey struct {
+	Type    string
+	Blob    string
+	Comment string
+}
+
+func (k sshKey) id() string {
+	return k.Type + " " + k.Blob
+}
+
+func (k sshKey) fingerprint() string {
+	blob, err := base64.StdEncoding.DecodeString(k.Blob)
+	if err != nil {
+		return "Invalid key"
+	}
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// parseSSHKey parses an authorized_keys line, ignoring options before the key
+// type. It returns false if the line does not contain a key.
+func parseSSHKey(line string) (sshKey, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' {
+		return sshKey{}, false
+	}
+
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		if i+1 >= len(fields) || !isSSHKeyType(field) {
+			continue
+		}
+		return sshKey{
+			Type:    field,
+			Blob:    fields[i+1],
+			Comment: strings.Join(fields[i+2:], " "),
+		}, true
+	}
+	return sshKey{}, false
+}
+
+func isSSHKeyType(field string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-sha2-", "sk-ssh-", "sk-ecdsa-sha2-"} {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type sshAuthorizedKey struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	user      string
+	keys      []string
+	exclusive bool
+
+	applying  atomic.Bool
+	fileClose func()
+	attrClose func()
+	closeChan chan struct{}
+}
+
+func (s *sshAuthorizedKey) UpdateVariables(vars variables.Variables) {
+	if s.vars.Update(vars) {
+		s.Stop()
+		s.Watch()
+	}
+}
+
+// Watch watches the authorized_keys file content, and the mode and ownership
+// of the .ssh directory and its files.
+func (s *sshAuthorizedKey) Watch() {
+	fileChan, fileClose := external.WatchFile(s.getPath())
+	s.fileClose = fileClose
+
+	attrChan, attrClose := external.WatchAttributes(filepath.Dir(s.getPath()))
+	s.attrClose = attrClose
+
+	closeChan := make(chan struct{})
+	s.closeChan = closeChan
+
+	go func() {
+		var fstatus external.FileStatus
+		for {
+			select {
+			case fstatus = <-fileChan:
+			case <-attrChan:
+			case <-closeChan:
+				return
+			}
+			if s.applying.Load() {
+				// No update if it is currently applying
+				continue
+			}
+			s.newStatus(fstatus)
+		}
+	}()
+}
+
+func (s *sshAuthorizedKey) Stop() {
+	if s.fileClose != nil {
+		s.fileClose()
+	}
+	if s.attrClose != nil {
+		s.attrClose()
+	}
+	if s.closeChan != nil {
+		close(s.closeChan)
+		s.closeChan = nil
+	}
+}
+
+func (s *sshAuthorizedKey) getPath() string {
+	return userHomePath(s.vars.Replace(s.user), ".ssh/authorized_keys")
+}
+
+func (s *sshAuthorizedKey) wantedKeys() []sshKey {
+	wanted := []sshKey{}
+	for _, line := range s.vars.ReplaceSlice(s.keys) {
+		if key, ok := parseSSHKey(line); ok {
+			wanted = append(wanted, key)
+		}
+	}
+	return wanted
+}
+
+func (s *sshAuthorizedKey) newStatus(fstatus external.FileStatus) {
+	switch fstatus {
+	case external.FileStatusDirectory:
+		s.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", s.getPath()), nil, nil)
+	case external.FileStatusUnknown:
+		s.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", s.getPath()), nil, nil)
+	default:
+		s.msg(s.check())
+	}
+}
+
+// readLines returns the lines of the authorized_keys file, which may not exist.
+func (s *sshAuthorizedKey) readLines() ([]string, error) {
+	// The user owns the .ssh directory: never follow links they may have put there
+	for _, path := range []string{filepath.Dir(s.getPath()), s.getPath()} {
+		if err := checkNotSymlink(path); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := os.ReadFile(s.getPath())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(content), "\n"), "\n"), nil
+}
+
+func (s *sshAuthorizedKey) check() (status.Status, string, status.Detail, variables.Variables) {
+	user := s.vars.Replace(s.user)
+
+	lines, err := s.readLines()
+	if err != nil {
+		return status.StatusFailed, fmt.Sprintf("Could not read %q", s.getPath()), status.Error(err.Error()), nil
+	}
+
+	current := map[string]sshKey{}
+	currentOrder := []sshKey{}
+	for _, line := range lines {
+		if key, ok := parseSSHKey(line); ok {
+			current[key.id()] = key
+			currentOrder = append(currentOrder, key)
+		}
+	}
+
+	result := status.Table{
+		Header: []string{"Type", "Fingerprint", "Comment", "Status"},
+	}
+
+	var missing, unexpected int
+
+	wanted := map[string]struct{}{}
+	for _, key := range s.wantedKeys() {
+		wanted[key.id()] = struct{}{}
+		keyStatus, content := status.StatusApplied, "Present"
+		if _, ok := current[key.id()]; !ok {
+			keyStatus, content = status.StatusTodo, "Missing"
+			missing++
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: key.Type},
+			status.TableCell{Status: status.StatusNone, Content: key.fingerprint()},
+			status.TableCell{Status: status.StatusNone, Content: key.Comment},
+			status.TableCell{Status: keyStatus, Content: content},
+		)
+	}
+
+	for _, key := range currentOrder {
+		if _, ok := wanted[key.id()]; ok {
+			continue
+		}
+		keyStatus, content := status.StatusNone, "Not managed"
+		if s.exclusive {
+			keyStatus, content = status.StatusTodo, "Unexpected"
+			unexpected++
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: key.Type},
+			status.TableCell{Status: status.StatusNone, Content: key.fingerprint()},
+			status.TableCell{Status: status.StatusNone, Content: key.Comment},
+			status.TableCell{Status: keyStatus, Content: content},
+		)
+	}
+
+	switch {
+	case missing > 0 && unexpected > 0:
+		return status.StatusTodo, fmt.Sprintf("Need to add %d and remove %d SSH keys for %s", missing, unexpected, user), &result, nil
+	case missing > 0:
+		return status.StatusTodo, fmt.Sprintf("Need to add %d SSH keys for %s", missing, user), &result, nil
+	case unexpected > 0:
+		return status.StatusTodo, fmt.Sprintf("Need to remove %d SSH keys for %s", unexpected, user), &result, nil
+	}
+
+	if problem := s.checkPermissions(); problem != "" {
+		return status.StatusTodo, problem, &result, nil
+	}
+
+	return status.StatusApplied, "SSH keys for " + user + " are as expected", &result, nil
+}
+
+// checkPermissions returns a description of the first permission problem on
+// the .ssh directory or the authorized_keys file, or "" if there is none.
+func (s *sshAuthorizedKey) checkPermissions() string {
+	userData, err := external.GetUser(s.vars.Replace(s.user))
+	if err != nil {
+		return fmt.Sprintf("Could not get user information for %q", s.vars.Replace(s.user))
+	}
+
+	for _, target := range []struct {
+		path string
+		mode fs.FileMode
+	}{
+		{filepath.Dir(s.getPath()), sshDirMode},
+		{s.getPath(), sshAuthorizedKeysMode},
+	} {
+		uid, gid, mode, err := fileOwnership(target.path)
+		if err != nil {
+			return fmt.Sprintf("Could not check status of %q", target.path)
+		}
+		if mode != target.mode {
+			return fmt.Sprintf("Need to change mode of %q to %04o", target.path, target.mode)
+		}
+		if uid != userData.ID || gid != userData.GID {
+			return fmt.Sprintf("Need to change ownership of %q to %s", target.path, userData.Name)
+		}
+	}
+
+	return ""
+}
+
+func (s *sshAuthorizedKey) Apply() bool {
+	s.applying.Store(true)
+	defer s.applying.Store(false)
+
+	path := s.getPath()
+	user := s.vars.Replace(s.user)
+
+	userData, err := external.GetUser(user)
+	if err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not get user information for %q", user), status.Error(err.Error()), nil)
+		return false
+	}
+
+	lines, err := s.readLines()
+	if err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	wanted := s.wantedKeys()
+	wantedIDs := map[string]struct{}{}
+	for _, key := range wanted {
+		wantedIDs[key.id()] = struct{}{}
+	}
+
+	var content strings.Builder
+	present := map[string]struct{}{}
+	for _, line := range lines {
+		if key, ok := parseSSHKey(line); ok {
+			if _, ok := wantedIDs[key.id()]; !ok && s.exclusive {
+				continue
+			}
+			present[key.id()] = struct{}{}
+		}
+		if line == "" && content.Len() == 0 {
+			continue
+		}
+		content.WriteString(line)
+		content.WriteByte('\n')
+	}
+	for _, key := range wanted {
+		if _, ok := present[key.id()]; ok {
+			continue
+		}
+		content.WriteString(strings.TrimSpace(key.id() + " " + key.Comment))
+		content.WriteByte('\n')
+	}
+
+	dir := filepath.Dir(path)
+	if err := s.prepareDir(dir, userData.ID, userData.GID); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not prepare %q", dir), status.Error(err.Error()), nil)
+		return false
+	}
+
+	// installFile replaces a symbolic link the user may have put at path,
+	// without following it
+	if err := installFile(strings.NewReader(content.String()), path, sshAuthorizedKeysMode, user); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	s.msg(status.StatusApplied, "Applied SSH keys for "+user, nil, nil)
+	return true
+}
+
+// prepareDir creates the .ssh directory if needed, then sets its mode and
+// ownership through a file descriptor, refusing to follow a symbolic link.
+func (s *sshAuthorizedKey) prepareDir(dir string, uid, gid int) error {
+	if err := os.Mkdir(dir, sshDirMode); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	f, err := os.OpenFile(dir, os.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		if errors.Is(err, syscall.ELOOP) {
+			return fmt.Errorf("%q is a symbolic link", dir)
+		}
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Chmod(sshDirMode); err != nil {
+		return err
+	}
+	return f.Chown(uid, gid)
+}
@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	sudoersDir              = "/etc/sudoers.d"
+	sudoersMode fs.FileMode = 0o440
+)
+
+var _ = registerFileWatcher(
+	"sudoers rule",
+	"key",
+	"Install a validated sudoers drop-in file",
+	ParamsDesc{
+		{"name", "File name", ParamTypeString},
+		{"content", "Rules", ParamTypeText},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &sudoersRule{
+			msg:     msg,
+			vars:    vars,
+			name:    params["name"].(string),
+			content: params["content"].(string),
+		}
+	},
+)
+
+type sudoersRule struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	name    string
+	content string
+}
+
+func (s *sudoersRule) updateVariables(vars variables.Variables) (changed bool) {
+	return s.vars.Update(vars)
+}
+
+func (s *sudoersRule) getPath() string {
+	return filepath.Join(sudoersDir, s.vars.Replace(s.name))
+}
+
+// expected returns the content of the file, which must end with a newline to
+// be accepted by sudo.
+func (s *sudoersRule) expected() string {
+	content := s.vars.Replace(s.content)
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content
+}
+
+// checkName returns an error message if sudo would ignore the file.
+func (s *sudoersRule) checkName() string {
+	name := s.vars.Replace(s.name)
+	if name == "" || strings.ContainsAny(name, "./") || strings.HasSuffix(name, "~") {
+		return fmt.Sprintf("%q is not a valid name for a sudoers drop-in file", name)
+	}
+	return ""
+}
+
+func (s *sudoersRule) newStatus(fstatus external.FileStatus) {
+	if problem := s.checkName(); problem != "" {
+		s.msg(status.StatusFailed, problem, nil, nil)
+		return
+	}
+
+	switch fstatus {
+	case external.FileStatusDirectory:
+		s.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", s.getPath()), nil, nil)
+	case external.FileStatusFile:
+		currentB, err := os.ReadFile(s.getPath())
+		if err != nil {
+			s.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", s.getPath()), status.Error(err.Error()), nil)
+			return
+		}
+		current := string(currentB)
+		if current != s.expected() {
+			s.msg(status.StatusTodo, fmt.Sprintf("Need to change %q", s.getPath()), status.TextDiff{Before: current, After: s.expected()}, nil)
+			return
+		}
+		uid, gid, mode, err := fileOwnership(s.getPath())
+		if err != nil {
+			s.msg(status.StatusFailed, fmt.Sprintf("Could not check status of %q", s.getPath()), status.Error(err.Error()), nil)
+			return
+		}
+		if mode != sudoersMode || uid != 0 || gid != 0 {
+			s.msg(status.StatusTodo, fmt.Sprintf("Need to change mode of %q to %04o and ownership to root", s.getPath(), sudoersMode), status.Text(current), nil)
+			return
+		}
+		s.msg(status.StatusApplied, fmt.Sprintf("%q has the required rules", s.getPath()), status.Text(current), nil)
+	case external.FileStatusUnknown:
+		s.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", s.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		s.msg(status.StatusTodo, fmt.Sprintf("Need to create %q", s.getPath()), status.Text(s.expected()), nil)
+	}
+}
+
+func (s *sudoersRule) apply() bool {
+	if problem := s.checkName(); problem != "" {
+		s.msg(status.StatusFailed, problem, nil, nil)
+		return false
+	}
+
+	path := s.getPath()
+
+	// sudo ignores files containing a dot in sudoers.d, the temporary file is
+	// therefore never used before being validated
+	tmpPath := filepath.Join(sudoersDir, "."+s.vars.Replace(s.name)+".keepakonf")
+	if err := os.WriteFile(tmpPath, []byte(s.expected()), sudoersMode); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", tmpPath), status.Error(err.Error()), nil)
+		return false
+	}
+	defer os.Remove(tmpPath)
+
+	if err := os.Chmod(tmpPath, sudoersMode); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not change mode of %q", tmpPath), status.Error(err.Error()), nil)
+		return false
+	}
+	if err := os.Chown(tmpPath, 0, 0); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not change %q ownership to root", tmpPath), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if output, err := external.VisudoCheck(tmpPath); err != nil {
+		if output == "" {
+			output = err.Error()
+		}
+		s.msg(status.StatusFailed, fmt.Sprintf("Invalid sudoers rules for %q", path), status.Error(output), nil)
+		return false
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not replace %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	s.msg(status.StatusApplied, fmt.Sprintf("Installed sudoers rules in %q", path), status.Text(s.expected()), nil)
+	return true
+}
@@ -0,0 +1,40 @@
+package commands
+
+import "testing"
+
+func TestSudoersRuleCheckName(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		valid bool
+	}{
+		{"keepakonf", true},
+		{"10-admins", true},
+		{"admins_rules", true},
+		{"", false},
+		{"admins.conf", false},
+		{"../sudoers", false},
+		{"sub/admins", false},
+		{"admins~", false},
+	} {
+		rule := sudoersRule{name: tc.name}
+		if problem := rule.checkName(); (problem == "") != tc.valid {
+			t.Errorf("checkName() for %q returned %q", tc.name, problem)
+		}
+	}
+}
+
+func TestSudoersRuleExpected(t *testing.T) {
+	for _, tc := range []struct {
+		content  string
+		expected string
+	}{
+		{"%admin ALL=(ALL) ALL", "%admin ALL=(ALL) ALL\n"},
+		{"%admin ALL=(ALL) ALL\n", "%admin ALL=(ALL) ALL\n"},
+		{"Defaults env_reset\n%admin ALL=(ALL) ALL", "Defaults env_reset\n%admin ALL=(ALL) ALL\n"},
+	} {
+		rule := sudoersRule{content: tc.content}
+		if content := rule.expected(); content != tc.expected {
+			t.Errorf("expected() for %q = %q, expected %q", tc.content, content, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	hostnamePath      = "/etc/hostname"
+	timezonePath      = "/etc/timezone"
+	localtimePath     = "/etc/localtime"
+	defaultLocalePath = "/etc/default/locale"
+)
+
+var _ = register(
+	"system identity",
+	"ubuntu",
+	"Set hostname, timezone and locales",
+	ParamsDesc{
+		{"hostname", "Hostname", ParamTypeString},
+		{"timezone", "Timezone (eg. Europe/Paris)", ParamTypeString},
+		{"locales", "Locales to generate (eg. en_US.UTF-8)", ParamTypeStringArray},
+		{"lang", "Default language (LANG)", ParamTypeString},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) Command {
+		return &systemIdentity{
+			msg:      msg,
+			vars:     vars,
+			hostname: params["hostname"].(string),
+			timezone: params["timezone"].(string),
+			locales:  params["locales"].([]string),
+			lang:     params["lang"].(string),
+		}
+	},
+)
+
+type systemIdentity struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	hostname string
+	timezone string
+	locales  []string
+	lang     string
+
+	applying   atomic.Bool
+	closeFuncs []func()
+	closeChan  chan struct{}
+}
+
+func (s *systemIdentity) UpdateVariables(vars variables.Variables) {
+	if s.vars.Update(vars) {
+		s.Stop()
+		s.Watch()
+	}
+}
+
+func (s *systemIdentity) Watch() {
+	closeChan := make(chan struct{})
+	s.closeChan = closeChan
+
+	changes := make(chan struct{}, 1)
+	for _, path := range []string{hostnamePath, timezonePath, localtimePath, defaultLocalePath} {
+		fileChan, fileClose := external.WatchFile(path)
+		s.closeFuncs = append(s.closeFuncs, fileClose)
+		go func() {
+			for {
+				select {
+				case <-fileChan:
+				case <-closeChan:
+					return
+				}
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+
+	go func() {
+		for {
+			select {
+			case <-changes:
+			case <-closeChan:
+				return
+			}
+			if s.applying.Load() {
+				// No update if it is currently applying
+				continue
+			}
+			s.msg(s.check())
+		}
+	}()
+}
+
+func (s *systemIdentity) Stop() {
+	for _, closeFunc := range s.closeFuncs {
+		closeFunc()
+	}
+	s.closeFuncs = nil
+	if s.closeChan != nil {
+		close(s.closeChan)
+		s.closeChan = nil
+	}
+}
+
+// currentHostname returns the static hostname.
+func currentHostname() (string, error) {
+	content, err := readOptionalFile(hostnamePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// currentTimezone returns the timezone /etc/localtime links to, or the content
+// of /etc/timezone if it is not a link.
+func currentTimezone() (string, error) {
+	target, err := os.Readlink(localtimePath)
+	if err == nil {
+		if _, zone, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return zone, nil
+		}
+		return filepath.Base(target), nil
+	}
+	content, err := readOptionalFile(timezonePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// currentLang returns the LANG value from /etc/default/locale.
+func currentLang() (string, error) {
+	content, err := readOptionalFile(defaultLocalePath)
+	if err != nil {
+		return "", err
+	}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "LANG="); ok {
+			return strings.Trim(value, `"'`), nil
+		}
+	}
+	return "", nil
+}
+
+// missingLocales returns the locales which are not generated yet.
+func (s *systemIdentity) missingLocales() ([]string, map[string]struct{}, error) {
+	generated, err := external.GeneratedLocales()
+	if err != nil {
+		return nil, nil, err
+	}
+	missing := []string{}
+	for _, locale := range s.vars.ReplaceSlice(s.locales) {
+		if _, ok := generated[external.NormalizeLocale(locale)]; !ok {
+			missing = append(missing, locale)
+		}
+	}
+	return missing, generated, nil
+}
+
+func (s *systemIdentity) check() (status.Status, string, status.Detail, variables.Variables) {
+	hostname, err := currentHostname()
+	if err != nil {
+		return status.StatusFailed, "Could not read hostname", status.Error(err.Error()), nil
+	}
+
+	timezone, err := currentTimezone()
+	if err != nil {
+		return status.StatusFailed, "Could not read timezone", status.Error(err.Error()), nil
+	}
+
+	lang, err := currentLang()
+	if err != nil {
+		return status.StatusFailed, "Could not read default locale", status.Error(err.Error()), nil
+	}
+
+	_, generated, err := s.missingLocales()
+	if err != nil {
+		return status.StatusFailed, "Could not list generated locales", status.Error(err.Error()), nil
+	}
+
+	result := status.Table{
+		Header: []string{"", "Current", "Expected"},
+	}
+
+	var todo bool
+	addRow := func(name, current, expected string, applied bool) {
+		rowStatus := status.StatusApplied
+		if !applied {
+			rowStatus = status.StatusTodo
+			todo = true
+		}
+		if current == "" {
+			current = "None"
+		}
+		result.AppendRow(
+			status.TableCell{Status: status.StatusNone, Content: name},
+			status.TableCell{Status: rowStatus, Content: current},
+			status.TableCell{Status: status.StatusNone, Content: expected},
+		)
+	}
+
+	// Empty parameters are not managed
+	if expectedHostname := s.vars.Replace(s.hostname); expectedHostname != "" {
+		addRow("Hostname", hostname, expectedHostname, hostname == expectedHostname)
+	}
+
+	if expectedTimezone := s.vars.Replace(s.timezone); expectedTimezone != "" {
+		addRow("Timezone", timezone, expectedTimezone, timezone == expectedTimezone)
+	}
+
+	for _, locale := range s.vars.ReplaceSlice(s.locales) {
+		_, ok := generated[external.NormalizeLocale(locale)]
+		current := "Not generated"
+		if ok {
+			current = "Generated"
+		}
+		addRow("Locale "+locale, current, "Generated", ok)
+	}
+
+	if expectedLang := s.vars.Replace(s.lang); expectedLang != "" {
+		addRow("Default language", lang, expectedLang, external.NormalizeLocale(lang) == external.NormalizeLocale(expectedLang))
+	}
+
+	if todo {
+		return status.StatusTodo, "Need to change system identity", &result, nil
+	}
+	return status.StatusApplied, "System identity is as expected", &result, nil
+}
+
+func (s *systemIdentity) Apply() bool {
+	s.applying.Store(true)
+	defer s.applying.Store(false)
+
+	// Setting a global variable refreshes every group, including this one:
+	// the new hostname is only published once applying is finished
+	var newHostname string
+	defer func() {
+		if newHostname != "" {
+			variables.SetGlobal("hostname", newHostname)
+		}
+	}()
+
+	hostname, err := currentHostname()
+	if err != nil {
+		s.msg(status.StatusFailed, "Could not read hostname", status.Error(err.Error()), nil)
+		return false
+	}
+	if expected := s.vars.Replace(s.hostname); expected != "" && hostname != expected {
+		if !external.Hostnamectl(stepReceiver(s.msg, "Setting hostname to "+expected), "set-hostname", expected) {
+			return false
+		}
+		newHostname = expected
+	}
+
+	timezone, err := currentTimezone()
+	if err != nil {
+		s.msg(status.StatusFailed, "Could not read timezone", status.Error(err.Error()), nil)
+		return false
+	}
+	if expected := s.vars.Replace(s.timezone); expected != "" && timezone != expected {
+		if !external.Timedatectl(stepReceiver(s.msg, "Setting timezone to "+expected), "set-timezone", expected) {
+			return false
+		}
+	}
+
+	missing, _, err := s.missingLocales()
+	if err != nil {
+		s.msg(status.StatusFailed, "Could not list generated locales", status.Error(err.Error()), nil)
+		return false
+	}
+	if len(missing) > 0 {
+		if !external.LocaleGen(stepReceiver(s.msg, "Generating locales "+strings.Join(missing, ", ")), missing...) {
+			return false
+		}
+	}
+
+	lang, err := currentLang()
+	if err != nil {
+		s.msg(status.StatusFailed, "Could not read default locale", status.Error(err.Error()), nil)
+		return false
+	}
+	if expected := s.vars.Replace(s.lang); expected != "" && external.NormalizeLocale(lang) != external.NormalizeLocale(expected) {
+		if !external.UpdateLocale(stepReceiver(s.msg, "Setting default language to "+expected), "LANG="+expected) {
+			return false
+		}
+	}
+
+	newStatus, info, detail, outVars := s.check()
+	s.msg(newStatus, info, detail, outVars)
+	return newStatus == status.StatusApplied
+}
@@ -0,0 +1,210 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/external"
+	"github.com/willoma/keepakonf/internal/status"
+	"github.com/willoma/keepakonf/internal/variables"
+)
+
+const (
+	systemdDir          = "/etc/systemd"
+	systemdOverrideName = "keepakonf.conf"
+)
+
+var _ = registerFileWatcher(
+	"systemd override",
+	"run",
+	"Override settings of a systemd unit or configuration file",
+	ParamsDesc{
+		{"unit", "Unit or configuration file (eg. ssh.service, logind.conf)", ParamTypeString},
+		{"user", "User unit (applied at next login)", ParamTypeBool},
+		{"entries", "Entries (Section.Key=Value)", ParamTypeStringArray},
+		{"restart", "Restart after change", ParamTypeBool},
+	},
+	func(params map[string]any, vars variables.Variables, msg status.SendStatus) fileWatcherCommand {
+		return &systemdOverride{
+			msg:     msg,
+			vars:    vars,
+			unit:    params["unit"].(string),
+			user:    params["user"].(bool),
+			entries: params["entries"].([]string),
+			restart: params["restart"].(bool),
+		}
+	},
+)
+
+type systemdOverride struct {
+	msg  status.SendStatus
+	vars variables.Variables
+
+	unit    string
+	user    bool
+	entries []string
+	restart bool
+}
+
+func (s *systemdOverride) updateVariables(vars variables.Variables) (changed bool) {
+	return s.vars.Update(vars)
+}
+
+// isConfig returns true if the override is for a configuration file of a
+// systemd daemon, like logind.conf, instead of a unit.
+func (s *systemdOverride) isConfig() bool {
+	return strings.HasSuffix(s.vars.Replace(s.unit), ".conf")
+}
+
+func (s *systemdOverride) getPath() string {
+	unit := s.vars.Replace(s.unit)
+	switch {
+	case s.isConfig():
+		return filepath.Join(systemdDir, unit+".d", systemdOverrideName)
+	case s.user:
+		return filepath.Join(systemdDir, "user", unit+".d", systemdOverrideName)
+	default:
+		return filepath.Join(systemdDir, "system", unit+".d", systemdOverrideName)
+	}
+}
+
+// restartUnit returns the unit to restart for the override to be taken into
+// account, or "" if it cannot be restarted.
+func (s *systemdOverride) restartUnit() string {
+	unit := s.vars.Replace(s.unit)
+	switch {
+	case s.user:
+		return ""
+	case s.isConfig():
+		// Configuration files of the manager itself need a reexec
+		if unit == "system.conf" || unit == "user.conf" {
+			return ""
+		}
+		return "systemd-" + strings.TrimSuffix(unit, ".conf") + ".service"
+	default:
+		return unit
+	}
+}
+
+// content returns the expected content of the drop-in file, with entries
+// grouped by section in order of appearance. Keys may be repeated, for
+// instance to reset ExecStart.
+func (s *systemdOverride) content() (string, error) {
+	sections := []string{}
+	entries := map[string][]string{}
+	for _, entry := range s.vars.ReplaceSlice(s.entries) {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return "", fmt.Errorf("entry %q is not in the Section.Key=Value format", entry)
+		}
+		section, key, ok := strings.Cut(name, ".")
+		if !ok || section == "" || key == "" {
+			return "", fmt.Errorf("entry %q is not in the Section.Key=Value format", entry)
+		}
+		if _, ok := entries[section]; !ok {
+			sections = append(sections, section)
+		}
+		entries[section] = append(entries[section], key+"="+value)
+	}
+
+	var content strings.Builder
+	content.WriteString("# Placed by Keepakonf\n")
+	for i, section := range sections {
+		if i > 0 {
+			content.WriteByte('\n')
+		}
+		content.WriteString("[" + section + "]\n")
+		for _, line := range entries[section] {
+			content.WriteString(line + "\n")
+		}
+	}
+	return content.String(), nil
+}
+
+func (s *systemdOverride) newStatus(fstatus external.FileStatus) {
+	expected, err := s.content()
+	if err != nil {
+		s.msg(status.StatusFailed, "Invalid entries for "+s.vars.Replace(s.unit), status.Error(err.Error()), nil)
+		return
+	}
+
+	switch fstatus {
+	case external.FileStatusDirectory:
+		s.msg(status.StatusFailed, fmt.Sprintf("%q is a directory", s.getPath()), nil, nil)
+	case external.FileStatusFile:
+		currentB, err := os.ReadFile(s.getPath())
+		if err != nil {
+			s.msg(status.StatusFailed, fmt.Sprintf("Could not read %q", s.getPath()), status.Error(err.Error()), nil)
+			return
+		}
+		current := string(currentB)
+		if current != expected {
+			s.msg(status.StatusTodo, "Need to change override for "+s.vars.Replace(s.unit), status.TextDiff{Before: current, After: expected}, nil)
+			return
+		}
+		s.msg(status.StatusApplied, "Override for "+s.vars.Replace(s.unit)+" is in place", status.Text(current), nil)
+	case external.FileStatusUnknown:
+		s.msg(status.StatusUnknown, fmt.Sprintf("%q status unknown", s.getPath()), nil, nil)
+	case external.FileStatusNotFound:
+		s.msg(status.StatusTodo, "Need to create override for "+s.vars.Replace(s.unit), status.Text(expected), nil)
+	}
+}
+
+func (s *systemdOverride) apply() bool {
+	unit := s.vars.Replace(s.unit)
+	path := s.getPath()
+
+	expected, err := s.content()
+	if err != nil {
+		s.msg(status.StatusFailed, "Invalid entries for "+unit, status.Error(err.Error()), nil)
+		return false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not create %q", filepath.Dir(path)), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if err := os.WriteFile(path, []byte(expected), 0o644); err != nil {
+		s.msg(status.StatusFailed, fmt.Sprintf("Could not write to %q", path), status.Error(err.Error()), nil)
+		return false
+	}
+
+	if s.user {
+		s.msg(status.StatusApplied, "Override for user unit "+unit+" will be used at next login", status.Text(expected), nil)
+		return true
+	}
+
+	if !s.isConfig() {
+		if !external.Systemctl(stepReceiver(s.msg, "Reloading systemd"), "daemon-reload") {
+			return false
+		}
+	}
+
+	if restartUnit := s.restartUnit(); s.restart && restartUnit != "" {
+		return external.Systemctl(
+			func(st status.Status, info string, detail status.Detail) {
+				if info == "" {
+					switch st {
+					case status.StatusRunning:
+						info = "Restarting " + restartUnit
+					case status.StatusApplied:
+						info = "Override for " + unit + " is in place, restarted " + restartUnit
+					case status.StatusFailed:
+						info = "Failed restarting " + restartUnit
+					}
+				}
+				s.msg(st, info, detail, nil)
+			},
+			"restart", restartUnit,
+		)
+	}
+
+	s.msg(status.StatusApplied, "Override for "+unit+" is in place", status.Text(expected), nil)
+	return true
+}
@@ -3,7 +3,6 @@ package commands
 import (
 	"bufio"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -79,13 +78,7 @@ func (x *xdgUserDir) updateVariables(vars variables.Variables) (changed bool) {
 }
 
 func (x *xdgUserDir) getPath() string {
-	userData, err := external.GetUser(x.vars.Replace(x.user))
-	if err != nil {
-		log.Errorf(err, "Could not get user information for %q", x.vars.Replace(x.user))
-		return filepath.Join(os.TempDir(), xdgUserDirPath)
-	}
-
-	return filepath.Join(userData.Home, xdgUserDirPath)
+	return userHomePath(x.vars.Replace(x.user), xdgUserDirPath)
 }
 
 func (x *xdgUserDir) newStatus(fstatus external.FileStatus) {
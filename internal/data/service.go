@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 
 	"github.com/willoma/keepakonf/internal/log"
 	"github.com/willoma/keepakonf/internal/runners"
+	"github.com/willoma/keepakonf/internal/variables"
 )
 
 const (
@@ -34,9 +36,22 @@ func New(io *socket.Server) *Data {
 		io: io,
 	}
 	d.load()
+	variables.OnGlobalChange(d.globalVariablesChanged)
 	return d
 }
 
+func (d *Data) globalVariablesChanged(global []variables.Variable) {
+	d.mu.Lock()
+	groups := slices.Clone(d.groups)
+	d.mu.Unlock()
+
+	for _, g := range groups {
+		g.RefreshVariables()
+	}
+
+	d.io.Sockets().Emit("global variables", global)
+}
+
 func (d *Data) load() {
 	f, err := os.ReadFile(dbfilepath)
 	if err != nil {
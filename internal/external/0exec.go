@@ -1,6 +1,9 @@
 package external
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -13,6 +16,32 @@ import (
 // 	return execCmd(env, output, "runuser", args...)
 // }
 
+// asUser returns the command and arguments needed to run cmd as username. If
+// username is empty, the command is run as root.
+func asUser(username, cmd string, args []string) (string, []string) {
+	if username == "" || username == "root" {
+		return cmd, args
+	}
+	return "runuser", append([]string{"-u", username, "--", cmd}, args...)
+}
+
+// execOutput runs a command and returns its standard output. If the command
+// fails, the returned error contains its standard error.
+func execOutput(env []string, cmd string, args ...string) (string, error) {
+	c := exec.Command(cmd, args...)
+	c.Env = append(os.Environ(), "LANG=C.UTF-8")
+	c.Env = append(c.Env, env...)
+	out, err := c.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return string(out), fmt.Errorf("%w: %s", err, bytes.TrimSpace(exitErr.Stderr))
+		}
+		return string(out), err
+	}
+	return string(out), nil
+}
+
 func execToMessage(
 	receiver func(status.Status, string, status.Detail),
 	env []string, cmd string, args ...string,
@@ -0,0 +1,107 @@
+package external
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+const (
+	alternativesAdminDir = "/var/lib/dpkg/alternatives"
+	alternativesLinksDir = "/etc/alternatives"
+)
+
+var errAlternativeFormat = errors.New("unexpected alternatives file format")
+
+type AlternativeCandidate struct {
+	Path     string
+	Priority int
+}
+
+type Alternative struct {
+	Name       string
+	Auto       bool
+	Link       string
+	Selected   string
+	Candidates []AlternativeCandidate
+}
+
+// AlternativeAdminPath returns the path to the dpkg administrative file for
+// the alternative.
+func AlternativeAdminPath(name string) string {
+	return filepath.Join(alternativesAdminDir, name)
+}
+
+// AlternativeLinkPath returns the path to the symbolic link for the
+// alternative.
+func AlternativeLinkPath(name string) string {
+	return filepath.Join(alternativesLinksDir, name)
+}
+
+// GetAlternative returns the alternative candidates and the currently
+// selected one.
+func GetAlternative(name string) (Alternative, error) {
+	f, err := os.Open(AlternativeAdminPath(name))
+	if err != nil {
+		return Alternative{}, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return Alternative{}, err
+	}
+
+	// Format: mode, master link, pairs of slave name and slave link until an
+	// empty line, then for each candidate: path, priority and one path per
+	// slave, until an empty line.
+	if len(lines) < 2 {
+		return Alternative{}, errAlternativeFormat
+	}
+
+	alt := Alternative{
+		Name: name,
+		Auto: lines[0] == "auto",
+		Link: lines[1],
+	}
+
+	i := 2
+	slaves := 0
+	for i+1 < len(lines) && lines[i] != "" {
+		slaves++
+		i += 2
+	}
+	i++
+
+	for i+1 < len(lines) && lines[i] != "" {
+		priority, err := strconv.Atoi(lines[i+1])
+		if err != nil {
+			return Alternative{}, errAlternativeFormat
+		}
+		alt.Candidates = append(alt.Candidates, AlternativeCandidate{
+			Path:     lines[i],
+			Priority: priority,
+		})
+		i += 2 + slaves
+	}
+
+	if selected, err := os.Readlink(AlternativeLinkPath(name)); err == nil {
+		alt.Selected = selected
+	}
+
+	return alt, nil
+}
+
+// UpdateAlternatives runs update-alternatives, sending its output to receiver.
+func UpdateAlternatives(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "update-alternatives", args...)
+}
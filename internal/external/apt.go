@@ -1,6 +1,8 @@
 package external
 
 import (
+	"strings"
+
 	"github.com/willoma/keepakonf/internal/status"
 )
 
@@ -25,3 +27,62 @@ func AptGet(
 		append([]string{"--yes", "--quiet", cmd}, args...)...,
 	)
 }
+
+// AptMark runs apt-mark, sending its output to receiver.
+func AptMark(
+	receiver func(status.Status, string, status.Detail),
+	cmd string,
+	args ...string,
+) bool {
+	if !dpkgMu.TryLock() {
+		receiver(status.StatusRunning, "Waiting for dpkg to be available", nil)
+		dpkgMu.Lock()
+	}
+	defer dpkgMu.Unlock()
+
+	return execToMessage(
+		receiver,
+		[]string{},
+		"apt-mark",
+		append([]string{cmd}, args...)...,
+	)
+}
+
+// AptRemoval is a package apt would remove.
+type AptRemoval struct {
+	Name    string
+	Version string
+}
+
+// AptSimulateRemovals runs an apt-get command in simulation mode and returns
+// the packages it would remove.
+func AptSimulateRemovals(cmd string, args ...string) ([]AptRemoval, error) {
+	out, err := execOutput(
+		[]string{"DEBIAN_FRONTEND=noninteractive"},
+		"apt-get",
+		append([]string{"--simulate", "--quiet", cmd}, args...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseAptRemovals(out), nil
+}
+
+// parseAptRemovals parses the output of an apt-get simulation.
+func parseAptRemovals(out string) []AptRemoval {
+	removals := []AptRemoval{}
+	for _, line := range strings.Split(out, "\n") {
+		// Purged packages are reported as "Purg" instead of "Remv"
+		fields, ok := strings.CutPrefix(line, "Remv ")
+		if !ok {
+			if fields, ok = strings.CutPrefix(line, "Purg "); !ok {
+				continue
+			}
+		}
+		name, version, _ := strings.Cut(fields, " ")
+		// The version may be followed by the reason, eg. "[1.0] (...)"
+		version, _, _ = strings.Cut(strings.TrimPrefix(version, "["), "]")
+		removals = append(removals, AptRemoval{Name: name, Version: version})
+	}
+	return removals
+}
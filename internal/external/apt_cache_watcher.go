@@ -34,6 +34,10 @@ type aptCacheWatcher struct {
 	packages     map[string]DpkgPackage
 	packagesList []DpkgPackage
 	packagesMu   sync.Mutex
+
+	// Only one apt-cache dumpavail at a time, so that an older list never
+	// overwrites a newer one
+	updateMu sync.Mutex
 }
 
 var (
@@ -87,6 +91,9 @@ func (a *aptCacheWatcher) run() {
 }
 
 func (a *aptCacheWatcher) updatePackagesList(knownPackages map[string]DpkgPackage) {
+	a.updateMu.Lock()
+	defer a.updateMu.Unlock()
+
 	cmd := exec.Command("apt-cache", "dumpavail")
 	reader, writer := io.Pipe()
 	cmd.Stdout = writer
@@ -96,6 +103,8 @@ func (a *aptCacheWatcher) updatePackagesList(knownPackages map[string]DpkgPackag
 	}
 
 	packages := map[string]DpkgPackage{}
+	// Installed packages for which another version is available
+	upgradable := map[string]struct{}{}
 
 	var (
 		pkg     string
@@ -115,13 +124,15 @@ func (a *aptCacheWatcher) updatePackagesList(knownPackages map[string]DpkgPackag
 					Name:             pkg,
 					AvailableVersion: version,
 				}
-				known, ok := knownPackages[pkg]
-				if ok {
+				if known, ok := knownPackages[pkg]; ok {
 					if known.Installed {
 						pkgObj.Version = known.Version
 						pkgObj.Installed = true
+						if version != known.Version {
+							upgradable[pkg] = struct{}{}
+						}
 					}
-					delete(knownPackages, pkg)
+					pkgObj.Want = known.Want
 				}
 				if _, ok := packages[pkg]; !ok || packages[pkg].AvailableVersion < pkgObj.AvailableVersion {
 					packages[pkg] = pkgObj
@@ -147,7 +158,9 @@ func (a *aptCacheWatcher) updatePackagesList(knownPackages map[string]DpkgPackag
 	}
 
 	for name, info := range knownPackages {
-		packages[name] = info
+		if _, ok := packages[name]; !ok {
+			packages[name] = info
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -159,8 +172,15 @@ func (a *aptCacheWatcher) updatePackagesList(knownPackages map[string]DpkgPackag
 		return
 	}
 
+	a.applyPolicy(packages, upgradable)
+
 	packagesList := make([]DpkgPackage, 0, len(packages))
-	for _, pkg := range packages {
+	for key, pkg := range packages {
+		// Packages qualified with their architecture are already listed
+		// under their name
+		if key != pkg.Name {
+			continue
+		}
 		packagesList = append(packagesList, pkg)
 	}
 
@@ -183,6 +203,116 @@ func (a *aptCacheWatcher) updatePackagesList(knownPackages map[string]DpkgPackag
 	a.receiversMu.Unlock()
 }
 
+// applyPolicy replaces the available version of upgradable packages with
+// their candidate version from apt-cache policy, which takes pinning into
+// account. Other installed packages are up to date whatever the policy, there
+// is no need to query apt-cache for the thousands of them.
+func (a *aptCacheWatcher) applyPolicy(packages map[string]DpkgPackage, upgradable map[string]struct{}) {
+	if len(upgradable) == 0 {
+		return
+	}
+	names := make([]string, 0, len(upgradable))
+	for name := range upgradable {
+		names = append(names, name)
+	}
+
+	candidates, err := AptCandidates("", names...)
+	if err != nil {
+		log.Error(err, "Could not get packages policy from apt cache")
+		return
+	}
+
+	for pkg, candidate := range candidates {
+		info, ok := packages[pkg]
+		if !ok {
+			continue
+		}
+		info.AvailableVersion = candidate.Version
+		info.Origins = candidate.Origins
+		info.Phased = candidate.Phased
+		packages[pkg] = info
+	}
+}
+
+// AptCandidate is the version apt would install for a package.
+type AptCandidate struct {
+	Version string
+	// Origins are the archives providing the version, eg. "bookworm-security"
+	Origins []string
+	// Phased is true if the version is being progressively rolled out
+	Phased bool
+}
+
+// AptCandidates returns the candidate versions of packages from apt-cache
+// policy, optionally for a target release. Packages without any candidate
+// have an empty version.
+func AptCandidates(release string, names ...string) (map[string]AptCandidate, error) {
+	args := []string{"policy"}
+	if release != "" {
+		args = append(args, "--target-release", release)
+	}
+
+	out, err := execOutput([]string{}, "apt-cache", append(args, names...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseAptPolicy(out), nil
+}
+
+// parseAptPolicy parses the output of apt-cache policy.
+func parseAptPolicy(out string) map[string]AptCandidate {
+	candidates := map[string]AptCandidate{}
+	var (
+		pkg         string
+		inCandidate bool
+	)
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' {
+			pkg = strings.TrimSuffix(line, ":")
+			inCandidate = false
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if version, ok := strings.CutPrefix(trimmed, "Candidate: "); ok {
+			if version == "(none)" {
+				version = ""
+			}
+			candidates[pkg] = AptCandidate{Version: version}
+			continue
+		}
+
+		candidate, ok := candidates[pkg]
+		if !ok || candidate.Version == "" {
+			continue
+		}
+
+		// Version table, versions are less indented than their sources:
+		// " *** 1.0-1 500"
+		// "        500 http://deb.debian.org/debian bookworm/main amd64 Packages"
+		fields := strings.Fields(strings.TrimPrefix(trimmed, "*** "))
+		if len(line)-len(strings.TrimLeft(line, " ")) <= 5 {
+			inCandidate = len(fields) > 0 && fields[0] == candidate.Version
+			if inCandidate {
+				candidate.Phased = strings.Contains(trimmed, "(phased ")
+				candidates[pkg] = candidate
+			}
+			continue
+		}
+		if !inCandidate || len(fields) < 3 {
+			// Sources without an archive, like /var/lib/dpkg/status
+			continue
+		}
+		origin, _, _ := strings.Cut(fields[2], "/")
+		candidate.Origins = append(candidate.Origins, origin)
+		candidates[pkg] = candidate
+	}
+	return candidates
+}
+
 func (a *aptCacheWatcher) listen() (target <-chan map[string]DpkgPackage, remove func()) {
 	targetChan := make(chan map[string]DpkgPackage, 2)
 
@@ -227,6 +357,12 @@ func (a *aptCacheWatcher) listPackages() map[string]DpkgPackage {
 	return a.packages
 }
 
+func (a *aptCacheWatcher) listPackagesList() []DpkgPackage {
+	a.packagesMu.Lock()
+	defer a.packagesMu.Unlock()
+	return a.packagesList
+}
+
 func initAptCacheWatcher() {
 	aptCacheWatcherRunnerOnce.Do(func() {
 		aptCacheWatcherRunner = &aptCacheWatcher{
@@ -258,3 +394,18 @@ func AptCachePackages() map[string]DpkgPackage {
 
 	return aptCacheWatcherRunner.listPackages()
 }
+
+// AptCachePackagesList returns the list of known packages and their potential update once.
+func AptCachePackagesList() []DpkgPackage {
+	initAptCacheWatcher()
+
+	return aptCacheWatcherRunner.listPackagesList()
+}
+
+// AptCacheRefresh reads the list of known packages again, for example after
+// apt preferences changed, and sends it to the listeners.
+func AptCacheRefresh() {
+	initAptCacheWatcher()
+
+	aptCacheWatcherRunner.updatePackagesList(DpkgPackages())
+}
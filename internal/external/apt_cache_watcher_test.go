@@ -0,0 +1,70 @@
+package external
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAptPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		out      string
+		expected map[string]AptCandidate
+	}{
+		{"empty", "", map[string]AptCandidate{}},
+		{
+			"installed and upgradable",
+			`vim:
+  Installed: 2:9.0.1378-2
+  Candidate: 2:9.0.1378-2+deb12u1
+  Version table:
+     2:9.0.1378-2+deb12u1 500
+        500 http://security.debian.org/debian-security bookworm-security/main amd64 Packages
+ *** 2:9.0.1378-2 500
+        500 http://deb.debian.org/debian bookworm/main amd64 Packages
+        100 /var/lib/dpkg/status
+`,
+			map[string]AptCandidate{
+				"vim": {Version: "2:9.0.1378-2+deb12u1", Origins: []string{"bookworm-security"}},
+			},
+		},
+		{
+			"several origins and packages",
+			`curl:
+  Installed: (none)
+  Candidate: 7.88.1-10+deb12u5
+  Version table:
+     7.88.1-10+deb12u5 500
+        500 http://deb.debian.org/debian bookworm/main amd64 Packages
+        500 http://security.debian.org/debian-security bookworm-security/main amd64 Packages
+libfoo:i386:
+  Installed: (none)
+  Candidate: (none)
+  Version table:
+`,
+			map[string]AptCandidate{
+				"curl":        {Version: "7.88.1-10+deb12u5", Origins: []string{"bookworm", "bookworm-security"}},
+				"libfoo:i386": {},
+			},
+		},
+		{
+			"phased",
+			`firefox:
+  Installed: 120.0+build2-0ubuntu0.22.04.1
+  Candidate: 121.0+build1-0ubuntu0.22.04.1
+  Version table:
+     121.0+build1-0ubuntu0.22.04.1 500 (phased 10%)
+        500 http://archive.ubuntu.com/ubuntu jammy-updates/main amd64 Packages
+ *** 120.0+build2-0ubuntu0.22.04.1 100
+        100 /var/lib/dpkg/status
+`,
+			map[string]AptCandidate{
+				"firefox": {Version: "121.0+build1-0ubuntu0.22.04.1", Origins: []string{"jammy-updates"}, Phased: true},
+			},
+		},
+	} {
+		if candidates := parseAptPolicy(tc.out); !reflect.DeepEqual(candidates, tc.expected) {
+			t.Errorf("%s: got %+v, expected %+v", tc.name, candidates, tc.expected)
+		}
+	}
+}
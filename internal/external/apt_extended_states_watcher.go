@@ -0,0 +1,149 @@
+package external
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/willoma/keepakonf/internal/log"
+)
+
+// This file is modified when apt or apt-mark change the auto/manual flags
+const aptExtendedStatesPath = "/var/lib/apt/extended_states"
+
+type aptExtendedStatesWatcher struct {
+	receivers   map[chan<- map[string]bool]struct{}
+	receiversMu sync.Mutex
+
+	auto   map[string]bool
+	autoMu sync.Mutex
+}
+
+var (
+	aptExtendedStatesWatcherRunner     *aptExtendedStatesWatcher
+	aptExtendedStatesWatcherRunnerOnce sync.Once
+)
+
+func (a *aptExtendedStatesWatcher) run() {
+	fstatusChan, _ := WatchFile(aptExtendedStatesPath)
+
+	a.scan()
+
+	go func() {
+		for range fstatusChan {
+			a.scan()
+
+			a.autoMu.Lock()
+			a.receiversMu.Lock()
+			for c := range a.receivers {
+				c <- a.auto
+			}
+			a.receiversMu.Unlock()
+			a.autoMu.Unlock()
+		}
+	}()
+}
+
+func (a *aptExtendedStatesWatcher) scan() {
+	auto := map[string]bool{}
+
+	f, err := os.Open(aptExtendedStatesPath)
+	if err != nil {
+		// The file does not exist if no package has ever been marked
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Error(err, "Could not open apt extended states")
+			return
+		}
+	} else {
+		defer f.Close()
+
+		var pkg string
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+
+			if line == "" {
+				pkg = ""
+				continue
+			}
+
+			info := strings.SplitN(line, ": ", 2)
+			if len(info) != 2 {
+				continue
+			}
+
+			switch info[0] {
+			case "Package":
+				pkg = info[1]
+			case "Auto-Installed":
+				// Packages may have several records, one per architecture
+				if pkg != "" && info[1] == "1" {
+					auto[pkg] = true
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			log.Error(err, "Could not read apt extended states")
+			return
+		}
+	}
+
+	a.autoMu.Lock()
+	a.auto = auto
+	a.autoMu.Unlock()
+}
+
+func (a *aptExtendedStatesWatcher) listen() (target <-chan map[string]bool, remove func()) {
+	targetChan := make(chan map[string]bool, 2)
+
+	a.autoMu.Lock()
+	targetChan <- a.auto
+	a.autoMu.Unlock()
+
+	a.receiversMu.Lock()
+	a.receivers[targetChan] = struct{}{}
+	a.receiversMu.Unlock()
+
+	return targetChan, func() {
+		a.receiversMu.Lock()
+		delete(a.receivers, targetChan)
+		a.receiversMu.Unlock()
+		close(targetChan)
+	}
+}
+
+func (a *aptExtendedStatesWatcher) listAuto() map[string]bool {
+	a.autoMu.Lock()
+	defer a.autoMu.Unlock()
+	return a.auto
+}
+
+func initAptExtendedStatesWatcher() {
+	aptExtendedStatesWatcherRunnerOnce.Do(func() {
+		aptExtendedStatesWatcherRunner = &aptExtendedStatesWatcher{
+			receivers: map[chan<- map[string]bool]struct{}{},
+			auto:      map[string]bool{},
+		}
+		aptExtendedStatesWatcherRunner.run()
+	})
+}
+
+// AptAutoListen returns the packages marked as automatically installed
+// whenever the list changes.
+func AptAutoListen() (target <-chan map[string]bool, remove func()) {
+	initAptExtendedStatesWatcher()
+
+	return aptExtendedStatesWatcherRunner.listen()
+}
+
+// AptAutoPackages returns the packages marked as automatically installed once.
+func AptAutoPackages() map[string]bool {
+	initAptExtendedStatesWatcher()
+
+	return aptExtendedStatesWatcherRunner.listAuto()
+}
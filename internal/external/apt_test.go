@@ -0,0 +1,42 @@
+package external
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseAptRemovals(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		out      string
+		expected []AptRemoval
+	}{
+		{"empty", "", []AptRemoval{}},
+		{
+			"nothing to remove",
+			"Reading package lists...\nBuilding dependency tree...\n0 upgraded, 0 newly installed, 0 to remove and 0 not upgraded.\n",
+			[]AptRemoval{},
+		},
+		{
+			"autoremove",
+			"The following packages will be REMOVED:\n" +
+				"  libfoo1 linux-image-6.1.0-1-amd64\n" +
+				"0 upgraded, 0 newly installed, 2 to remove and 0 not upgraded.\n" +
+				"Remv libfoo1 [1.2-3]\n" +
+				"Remv linux-image-6.1.0-1-amd64 [6.1.4-1] [linux-base:amd64 ]\n",
+			[]AptRemoval{
+				{Name: "libfoo1", Version: "1.2-3"},
+				{Name: "linux-image-6.1.0-1-amd64", Version: "6.1.4-1"},
+			},
+		},
+		{
+			"purge with epoch and architecture",
+			"Purg libbar:i386 [1:2.0~rc1-1]\nInst baz (2.0 Debian:12/stable [amd64])\nConf baz (2.0 Debian:12/stable [amd64])\n",
+			[]AptRemoval{{Name: "libbar:i386", Version: "1:2.0~rc1-1"}},
+		},
+	} {
+		if removals := parseAptRemovals(tc.out); !slices.Equal(removals, tc.expected) {
+			t.Errorf("%s: got %+v, expected %+v", tc.name, removals, tc.expected)
+		}
+	}
+}
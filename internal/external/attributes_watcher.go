@@ -0,0 +1,105 @@
+package external
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/willoma/keepakonf/internal/log"
+)
+
+// WatchAttributes sends a signal whenever the mode or the ownership of dir, or
+// of a file in dir, changes. The directory may not exist yet, it is watched
+// once it is created.
+func WatchAttributes(dir string) (target <-chan struct{}, remove func()) {
+	targetChan := make(chan struct{}, 1)
+	closeChan := make(chan struct{})
+
+	go watchAttributes(dir, targetChan, closeChan)
+
+	var once sync.Once
+	return targetChan, func() {
+		once.Do(func() {
+			close(closeChan)
+		})
+	}
+}
+
+func watchAttributes(dir string, target chan<- struct{}, closeChan <-chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf(err, "Could not watch attributes of %q", dir)
+		return
+	}
+	defer watcher.Close()
+
+	signal := func() {
+		select {
+		case target <- struct{}{}:
+		default:
+		}
+	}
+
+	for first := true; ; first = false {
+		if err := watcher.Add(dir); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Errorf(err, "Could not watch attributes of %q", dir)
+				return
+			}
+			if !waitForDirectory(dir, closeChan) {
+				return
+			}
+			continue
+		}
+		if !first {
+			// The directory has been created again, with new attributes
+			signal()
+		}
+
+	events:
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				switch {
+				case event.Has(fsnotify.Chmod):
+					// Also reported for ownership changes
+					signal()
+				case event.Name == dir && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)):
+					// A moved directory would still be watched
+					watcher.Remove(dir)
+					break events
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Errorf(err, "Could not monitor attributes of %q", dir)
+			case <-closeChan:
+				return
+			}
+		}
+	}
+}
+
+// waitForDirectory returns true once dir exists, or false if closeChan is
+// closed first.
+func waitForDirectory(dir string, closeChan <-chan struct{}) bool {
+	fstatusChan, remove := WatchFile(dir)
+	defer remove()
+
+	for {
+		select {
+		case fstatus := <-fstatusChan:
+			if fstatus == FileStatusDirectory {
+				return true
+			}
+		case <-closeChan:
+			return false
+		}
+	}
+}
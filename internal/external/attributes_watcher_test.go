@@ -0,0 +1,52 @@
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchAttributes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".ssh")
+
+	signals, remove := WatchAttributes(dir)
+	defer remove()
+
+	expectSignal := func(t *testing.T, what string) {
+		t.Helper()
+		select {
+		case <-signals:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no signal after %s", what)
+		}
+	}
+
+	// The directory does not exist yet, let the watcher wait for it
+	time.Sleep(100 * time.Millisecond)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	expectSignal(t, "creating the directory")
+
+	path := filepath.Join(dir, "authorized_keys")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Drain signals from the file creation
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-signals:
+	default:
+	}
+
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectSignal(t, "changing the file mode")
+
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	expectSignal(t, "changing the directory mode")
+}
@@ -0,0 +1,84 @@
+package external
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+const (
+	// DebconfConfigPath is the debconf database containing questions values
+	DebconfConfigPath    = "/var/cache/debconf/config.dat"
+	debconfPasswordsPath = "/var/cache/debconf/passwords.dat"
+
+	// Some values (eg. lists of certificates) are longer than the default of
+	// 64k characters.
+	debconfScannerBufferLength = 1024 * 1024
+)
+
+// DebconfValues returns the values of the questions known by debconf,
+// including passwords.
+func DebconfValues() (map[string]string, error) {
+	values := map[string]string{}
+	for _, path := range []string{DebconfConfigPath, debconfPasswordsPath} {
+		if err := readDebconfDatabase(path, values); err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == debconfPasswordsPath {
+				continue
+			}
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func readDebconfDatabase(path string, values map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var name string
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer([]byte{}, debconfScannerBufferLength)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			name = ""
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Name":
+			name = strings.TrimSpace(value)
+			values[name] = ""
+		case "Value":
+			if name != "" {
+				values[name] = strings.TrimPrefix(value, " ")
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// DebconfSetSelections sets debconf answers from the file at path, sending
+// the output to receiver.
+func DebconfSetSelections(receiver func(status.Status, string, status.Detail), path string) bool {
+	// debconf cannot be modified while dpkg is running
+	if !dpkgMu.TryLock() {
+		receiver(status.StatusRunning, "Waiting for dpkg to be available", nil)
+		dpkgMu.Lock()
+	}
+	defer dpkgMu.Unlock()
+
+	return execToMessage(receiver, []string{}, "debconf-set-selections", path)
+}
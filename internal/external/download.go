@@ -0,0 +1,96 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// Interval between two progress reports during a download
+	downloadProgressInterval = 500 * time.Millisecond
+	// Maximum duration of a whole download
+	downloadTimeout = time.Hour
+)
+
+// Maximum duration without receiving any data, a variable to allow shorter
+// delays in tests
+var downloadStallTimeout = time.Minute
+
+var downloadClient = &http.Client{
+	Timeout: downloadTimeout,
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+type downloadProgressWriter struct {
+	done     int64
+	total    int64
+	last     time.Time
+	progress func(done, total int64)
+	stall    *time.Timer
+}
+
+func (d *downloadProgressWriter) Write(p []byte) (int, error) {
+	d.stall.Reset(downloadStallTimeout)
+	d.done += int64(len(p))
+	if time.Since(d.last) >= downloadProgressInterval {
+		d.last = time.Now()
+		d.progress(d.done, d.total)
+	}
+	return len(p), nil
+}
+
+// Download writes the content available at url to w. It regularly calls
+// progress with the number of bytes downloaded so far and the total size,
+// which is -1 if the server did not provide it.
+func Download(url string, w io.Writer, progress func(done, total int64)) error {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// Abort if the server stops sending data
+	stall := time.AfterFunc(downloadStallTimeout, func() {
+		cancel(fmt.Errorf("no data received for %s", downloadStallTimeout))
+	})
+	defer stall.Stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status %q", resp.Status)
+	}
+
+	pw := &downloadProgressWriter{
+		total:    resp.ContentLength,
+		last:     time.Now(),
+		progress: progress,
+		stall:    stall,
+	}
+	if _, err := io.Copy(io.MultiWriter(w, pw), resp.Body); err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	progress(pw.done, pw.total)
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package external
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	content := strings.Repeat("keepakonf", 1000)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write([]byte(content))
+	})
+	mux.HandleFunc("/stall", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("success", func(t *testing.T) {
+		var (
+			buf          bytes.Buffer
+			done, total  int64
+			progressCall int
+		)
+		err := Download(server.URL+"/file", &buf, func(d, t int64) {
+			done, total = d, t
+			progressCall++
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.String() != content {
+			t.Errorf("got %d bytes, expected %d", buf.Len(), len(content))
+		}
+		if progressCall == 0 {
+			t.Error("progress never called")
+		}
+		if done != int64(len(content)) || total != int64(len(content)) {
+			t.Errorf("final progress %d/%d, expected %d/%d", done, total, len(content), len(content))
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		err := Download(server.URL+"/missing", &bytes.Buffer{}, func(int64, int64) {})
+		if err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("expected a 404 error, got %v", err)
+		}
+	})
+
+	t.Run("stall", func(t *testing.T) {
+		previous := downloadStallTimeout
+		downloadStallTimeout = 200 * time.Millisecond
+		defer func() { downloadStallTimeout = previous }()
+
+		start := time.Now()
+		err := Download(server.URL+"/stall", &bytes.Buffer{}, func(int64, int64) {})
+		if err == nil || !strings.Contains(err.Error(), "no data received") {
+			t.Errorf("expected a stall error, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("stalled download aborted after %s", elapsed)
+		}
+	})
+}
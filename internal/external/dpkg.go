@@ -1,12 +1,85 @@
 package external
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/willoma/keepakonf/internal/log"
+)
 
 type DpkgPackage struct {
 	Name             string
+	Architecture     string
 	Installed        bool
 	Version          string
 	AvailableVersion string
+	// Origins are the archives providing the available version
+	Origins []string
+	// Phased is true if the available version is being progressively rolled
+	// out
+	Phased bool
+	// Want is the selection state of the package: "install", "hold",
+	// "deinstall", "purge" or "unknown".
+	Want string
+}
+
+// Held returns true if the package is marked as held.
+func (d DpkgPackage) Held() bool {
+	return d.Want == "hold"
 }
 
 var dpkgMu sync.Mutex
+
+var (
+	dpkgArchitecture     string
+	dpkgArchitectureOnce sync.Once
+)
+
+// DpkgArchitecture returns the native architecture, or "" if it is unknown.
+func DpkgArchitecture() string {
+	dpkgArchitectureOnce.Do(func() {
+		out, err := execOutput(nil, "dpkg", "--print-architecture")
+		if err != nil {
+			log.Error(err, "Could not get dpkg architecture")
+			return
+		}
+		dpkgArchitecture = strings.TrimSpace(out)
+	})
+	return dpkgArchitecture
+}
+
+// DebInfo returns the package name and version of a .deb file.
+func DebInfo(path string) (name, version string, err error) {
+	out, err := execOutput(nil, "dpkg-deb", "--field", path, "Package", "Version")
+	if err != nil {
+		return "", "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if value, ok := strings.CutPrefix(line, "Package: "); ok {
+			name = strings.TrimSpace(value)
+		} else if value, ok := strings.CutPrefix(line, "Version: "); ok {
+			version = strings.TrimSpace(value)
+		}
+	}
+	if name == "" || version == "" {
+		return "", "", fmt.Errorf("could not read package name and version from %q", path)
+	}
+	return name, version, nil
+}
+
+// DpkgCompareVersions compares two package versions with dpkg, which knows
+// about epochs and revisions. The operator is one of lt, le, eq, ne, ge or gt.
+func DpkgCompareVersions(a, op, b string) (bool, error) {
+	err := exec.Command("dpkg", "--compare-versions", a, op, b).Run()
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, fmt.Errorf("could not compare versions %q and %q: %w", a, b, err)
+}
@@ -2,6 +2,7 @@ package external
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -96,30 +97,54 @@ func (d *dpkgWatcher) scan() {
 	}
 	defer f.Close()
 
+	packages, err := parseDpkgStatus(f)
+	if err != nil {
+		log.Error(err, "Could not read dpkg status")
+		return
+	}
+
+	d.packagesMu.Lock()
+	d.packages = packages
+	d.packagesMu.Unlock()
+}
+
+// parseDpkgStatus parses the content of the dpkg status file.
+func parseDpkgStatus(r io.Reader) (map[string]DpkgPackage, error) {
 	packages := map[string]DpkgPackage{}
 
 	var (
 		pkg       string
+		arch      string
 		version   string
 		installed bool
+		want      string
 	)
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+		line := scanner.Text()
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			// Continuation of a multiline field, like Description
+			continue
+		}
+		line = strings.TrimSpace(line)
 
 		if line == "" {
 			// End of record, store it
 			if pkg != "" {
-				packages[pkg] = DpkgPackage{
-					Name:      pkg,
-					Version:   version,
-					Installed: installed,
-				}
+				storeDpkgPackage(packages, DpkgPackage{
+					Name:         pkg,
+					Architecture: arch,
+					Version:      version,
+					Installed:    installed,
+					Want:         want,
+				})
 			}
 			installed = false
 			pkg = ""
+			arch = ""
 			version = ""
+			want = ""
 			continue
 		}
 
@@ -131,30 +156,43 @@ func (d *dpkgWatcher) scan() {
 		switch info[0] {
 		case "Package":
 			pkg = info[1]
+		case "Architecture":
+			arch = info[1]
 		case "Version":
 			version = info[1]
 		case "Status":
-			if info[1] == "install ok installed" {
-				installed = true
+			// Format: want flag status
+			fields := strings.Fields(info[1])
+			if len(fields) == 3 {
+				want = fields[0]
+				installed = fields[2] == "installed"
 			}
 		}
 	}
 
 	if pkg != "" {
-		packages[pkg] = DpkgPackage{
-			Version:   version,
-			Installed: installed,
-		}
+		storeDpkgPackage(packages, DpkgPackage{
+			Name:         pkg,
+			Architecture: arch,
+			Version:      version,
+			Installed:    installed,
+			Want:         want,
+		})
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Error(err, "Could not read dpkg status")
-		return
-	}
+	return packages, scanner.Err()
+}
 
-	d.packagesMu.Lock()
-	d.packages = packages
-	d.packagesMu.Unlock()
+// storeDpkgPackage stores a package both by name and by name qualified with
+// its architecture. Installed packages take precedence when multiple
+// architectures share the same name.
+func storeDpkgPackage(packages map[string]DpkgPackage, pkg DpkgPackage) {
+	if pkg.Architecture != "" {
+		packages[pkg.Name+":"+pkg.Architecture] = pkg
+	}
+	if existing, ok := packages[pkg.Name]; !ok || !existing.Installed {
+		packages[pkg.Name] = pkg
+	}
 }
 
 func (d *dpkgWatcher) listen() (target <-chan map[string]DpkgPackage, remove func()) {
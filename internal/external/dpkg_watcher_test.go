@@ -0,0 +1,68 @@
+package external
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDpkgStatus = `Package: vim
+Status: install ok installed
+Priority: optional
+Architecture: amd64
+Version: 2:9.0.1378-2
+Description: Vi IMproved - enhanced vi editor
+ Vim is an almost compatible version of the UNIX editor Vi.
+ .
+ Version: not a field
+
+Package: libfoo
+Status: hold ok installed
+Architecture: i386
+Version: 1.0-1
+
+Package: libfoo
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 0.9-1
+
+Package: removed
+Status: purge ok not-installed
+Architecture: amd64
+
+Package: fonts-bar
+Status: install ok installed
+Architecture: all
+Version: 1.2`
+
+func TestParseDpkgStatus(t *testing.T) {
+	packages, err := parseDpkgStatus(strings.NewReader(testDpkgStatus))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	vim := DpkgPackage{Name: "vim", Architecture: "amd64", Installed: true, Version: "2:9.0.1378-2", Want: "install"}
+	libfooI386 := DpkgPackage{Name: "libfoo", Architecture: "i386", Installed: true, Version: "1.0-1", Want: "hold"}
+	libfooAmd64 := DpkgPackage{Name: "libfoo", Architecture: "amd64", Version: "0.9-1", Want: "deinstall"}
+	removed := DpkgPackage{Name: "removed", Architecture: "amd64", Want: "purge"}
+	fontsBar := DpkgPackage{Name: "fonts-bar", Architecture: "all", Installed: true, Version: "1.2", Want: "install"}
+
+	expected := map[string]DpkgPackage{
+		"vim":           vim,
+		"vim:amd64":     vim,
+		"libfoo":        libfooI386, // The installed architecture takes precedence
+		"libfoo:i386":   libfooI386,
+		"libfoo:amd64":  libfooAmd64,
+		"removed":       removed,
+		"removed:amd64": removed,
+		"fonts-bar":     fontsBar,
+		"fonts-bar:all": fontsBar,
+	}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("got %+v\nexpected %+v", packages, expected)
+	}
+
+	if !packages["libfoo"].Held() || packages["vim"].Held() {
+		t.Error("unexpected held state")
+	}
+}
@@ -0,0 +1,36 @@
+package external
+
+import (
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+// FcCache rebuilds the font cache for dir as username, sending the output to
+// receiver.
+func FcCache(receiver func(status.Status, string, status.Detail), username, dir string) bool {
+	cmd, args := asUser(username, "fc-cache", []string{"--force", dir})
+	return execToMessage(receiver, []string{}, cmd, args...)
+}
+
+// FcList returns the family of each font file known by fontconfig for
+// username.
+func FcList(username string) (map[string]string, error) {
+	cmd, args := asUser(username, "fc-list", []string{"--format", "%{file}\t%{family}\n"})
+	out, err := execOutput(nil, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	families := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		file, family, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		// Keep the first name of families with translated names
+		family, _, _ = strings.Cut(family, ",")
+		families[file] = family
+	}
+	return families, nil
+}
@@ -0,0 +1,29 @@
+package external
+
+import (
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+func gitArgs(dir string, args []string) []string {
+	return append([]string{"-c", "safe.directory=" + dir, "-C", dir}, args...)
+}
+
+// Git runs git in dir as username, sending its output to receiver.
+func Git(
+	receiver func(status.Status, string, status.Detail),
+	username, dir string,
+	args ...string,
+) bool {
+	cmd, args := asUser(username, "git", gitArgs(dir, args))
+	return execToMessage(receiver, []string{}, cmd, args...)
+}
+
+// GitOutput runs git in dir as username and returns its trimmed output. It
+// must only be used for commands which do not modify the repository.
+func GitOutput(username, dir string, args ...string) (string, error) {
+	cmd, args := asUser(username, "git", gitArgs(dir, args))
+	out, err := execOutput([]string{"GIT_OPTIONAL_LOCKS=0"}, cmd, args...)
+	return strings.TrimSpace(out), err
+}
@@ -0,0 +1,56 @@
+package external
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+const procModulesPath = "/proc/modules"
+
+// LoadedKernelModules returns the names of the currently loaded kernel
+// modules. Dashes are replaced with underscores, as the kernel does.
+func LoadedKernelModules() (map[string]struct{}, error) {
+	f, err := os.Open(procModulesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	modules := map[string]struct{}{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, _, _ := strings.Cut(scanner.Text(), " ")
+		if name != "" {
+			modules[KernelModuleName(name)] = struct{}{}
+		}
+	}
+
+	return modules, scanner.Err()
+}
+
+// KernelModuleName returns the name of a module as known by the kernel.
+func KernelModuleName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// Modprobe runs modprobe, sending its output to receiver.
+func Modprobe(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "modprobe", args...)
+}
+
+// UpdateInitramfs updates the initramfs of all installed kernels, sending the
+// output to receiver.
+func UpdateInitramfs(receiver func(status.Status, string, status.Detail)) bool {
+	// update-initramfs may also be triggered by dpkg
+	if !dpkgMu.TryLock() {
+		receiver(status.StatusRunning, "Waiting for dpkg to be available", nil)
+		dpkgMu.Lock()
+	}
+	defer dpkgMu.Unlock()
+
+	return execToMessage(receiver, []string{}, "update-initramfs", "-u", "-k", "all")
+}
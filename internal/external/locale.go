@@ -0,0 +1,48 @@
+package external
+
+import (
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+// NormalizeLocale returns the name of a locale as listed by "locale -a", with
+// a lowercase codeset without dashes.
+func NormalizeLocale(name string) string {
+	lang, codeset, ok := strings.Cut(name, ".")
+	if !ok {
+		return name
+	}
+	modifier := ""
+	if codeset, modifier, ok = strings.Cut(codeset, "@"); ok {
+		modifier = "@" + modifier
+	}
+	return lang + "." + strings.ReplaceAll(strings.ToLower(codeset), "-", "") + modifier
+}
+
+// GeneratedLocales returns the normalized names of the available locales.
+func GeneratedLocales() (map[string]struct{}, error) {
+	out, err := execOutput(nil, "locale", "-a")
+	if err != nil {
+		return nil, err
+	}
+
+	locales := map[string]struct{}{}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			locales[NormalizeLocale(line)] = struct{}{}
+		}
+	}
+	return locales, nil
+}
+
+// LocaleGen generates locales, sending the output to receiver.
+func LocaleGen(receiver func(status.Status, string, status.Detail), locales ...string) bool {
+	return execToMessage(receiver, []string{}, "locale-gen", locales...)
+}
+
+// UpdateLocale changes the system locale settings, sending the output to
+// receiver.
+func UpdateLocale(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "update-locale", args...)
+}
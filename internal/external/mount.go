@@ -0,0 +1,96 @@
+package external
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/willoma/keepakonf/internal/status"
+)
+
+const mountInfoPath = "/proc/self/mountinfo"
+
+type MountPoint struct {
+	Path    string
+	Source  string
+	FsType  string
+	Options string
+}
+
+// UnescapeMountField decodes the octal escapes (eg. "\040" for a space) used
+// in /etc/fstab and /proc/self/mountinfo fields.
+func UnescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var result strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			if c, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				result.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		result.WriteByte(field[i])
+	}
+	return result.String()
+}
+
+// EscapeMountField encodes the characters which cannot appear as-is in
+// /etc/fstab fields.
+func EscapeMountField(field string) string {
+	return strings.NewReplacer(
+		`\`, `\134`,
+		" ", `\040`,
+		"\t", `\011`,
+		"\n", `\012`,
+	).Replace(field)
+}
+
+// MountPoints returns the currently active mount points, by path.
+func MountPoints() (map[string]MountPoint, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts := map[string]MountPoint{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Format: id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		sep := 6
+		for sep < len(fields) && fields[sep] != "-" {
+			sep++
+		}
+		if sep+2 >= len(fields) {
+			continue
+		}
+		path := UnescapeMountField(fields[4])
+		mounts[path] = MountPoint{
+			Path:    path,
+			Source:  UnescapeMountField(fields[sep+2]),
+			FsType:  fields[sep+1],
+			Options: fields[5],
+		}
+	}
+
+	return mounts, scanner.Err()
+}
+
+// Mount runs the mount command, sending its output to receiver.
+func Mount(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "mount", args...)
+}
+
+// Umount runs the umount command, sending its output to receiver.
+func Umount(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "umount", args...)
+}
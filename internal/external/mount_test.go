@@ -0,0 +1,28 @@
+package external
+
+import "testing"
+
+func TestMountFieldEscape(t *testing.T) {
+	for _, tc := range []struct {
+		raw     string
+		escaped string
+	}{
+		{"/dev/sda1", "/dev/sda1"},
+		{"/mnt/my share", `/mnt/my\040share`},
+		{"a\tb\nc", `a\011b\012c`},
+		{`back\slash`, `back\134slash`},
+	} {
+		if escaped := EscapeMountField(tc.raw); escaped != tc.escaped {
+			t.Errorf("EscapeMountField(%q) = %q, expected %q", tc.raw, escaped, tc.escaped)
+		}
+		if raw := UnescapeMountField(tc.escaped); raw != tc.raw {
+			t.Errorf("UnescapeMountField(%q) = %q, expected %q", tc.escaped, raw, tc.raw)
+		}
+	}
+
+	for _, field := range []string{`trailing\04`, `not\octal`, `end\`} {
+		if raw := UnescapeMountField(field); raw != field {
+			t.Errorf("UnescapeMountField(%q) = %q, expected it unchanged", field, raw)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package external
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/willoma/keepakonf/internal/log"
+)
+
+// These files are created by packages needing a reboot, like the kernel
+const (
+	rebootRequiredPath     = "/var/run/reboot-required"
+	rebootRequiredPkgsPath = "/var/run/reboot-required.pkgs"
+)
+
+// RebootRequired tells if a reboot is pending, and which packages require it.
+type RebootRequired struct {
+	Required bool     `json:"required"`
+	Packages []string `json:"packages"`
+}
+
+type rebootRequiredWatcher struct {
+	receivers   map[chan<- RebootRequired]struct{}
+	receiversMu sync.Mutex
+
+	state   RebootRequired
+	stateMu sync.Mutex
+}
+
+var (
+	rebootRequiredWatcherRunner     *rebootRequiredWatcher
+	rebootRequiredWatcherRunnerOnce sync.Once
+)
+
+func (r *rebootRequiredWatcher) run() {
+	requiredChan, _ := WatchFile(rebootRequiredPath)
+	pkgsChan, _ := WatchFile(rebootRequiredPkgsPath)
+
+	r.scan()
+
+	go func() {
+		for {
+			select {
+			case <-requiredChan:
+			case <-pkgsChan:
+			}
+
+			r.scan()
+
+			r.stateMu.Lock()
+			r.receiversMu.Lock()
+			for c := range r.receivers {
+				c <- r.state
+			}
+			r.receiversMu.Unlock()
+			r.stateMu.Unlock()
+		}
+	}()
+}
+
+func (r *rebootRequiredWatcher) scan() {
+	state := RebootRequired{Packages: []string{}}
+
+	if _, err := os.Stat(rebootRequiredPath); err == nil {
+		state.Required = true
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Error(err, "Could not check if a reboot is required")
+	}
+
+	pkgs, err := os.ReadFile(rebootRequiredPkgsPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error(err, "Could not read packages requiring a reboot")
+	}
+	// Packages may be listed several times, when upgraded several times
+	for _, pkg := range strings.Split(string(pkgs), "\n") {
+		if pkg = strings.TrimSpace(pkg); pkg != "" && !slices.Contains(state.Packages, pkg) {
+			state.Packages = append(state.Packages, pkg)
+		}
+	}
+
+	r.stateMu.Lock()
+	r.state = state
+	r.stateMu.Unlock()
+}
+
+func (r *rebootRequiredWatcher) listen() (target <-chan RebootRequired, remove func()) {
+	targetChan := make(chan RebootRequired, 2)
+
+	r.stateMu.Lock()
+	targetChan <- r.state
+	r.stateMu.Unlock()
+
+	r.receiversMu.Lock()
+	r.receivers[targetChan] = struct{}{}
+	r.receiversMu.Unlock()
+
+	return targetChan, func() {
+		r.receiversMu.Lock()
+		delete(r.receivers, targetChan)
+		r.receiversMu.Unlock()
+		close(targetChan)
+	}
+}
+
+func (r *rebootRequiredWatcher) getState() RebootRequired {
+	r.stateMu.Lock()
+	defer r.stateMu.Unlock()
+	return r.state
+}
+
+func initRebootRequiredWatcher() {
+	rebootRequiredWatcherRunnerOnce.Do(func() {
+		rebootRequiredWatcherRunner = &rebootRequiredWatcher{
+			receivers: map[chan<- RebootRequired]struct{}{},
+			state:     RebootRequired{Packages: []string{}},
+		}
+		rebootRequiredWatcherRunner.run()
+	})
+}
+
+// RebootRequiredListen returns whether a reboot is required whenever it
+// changes.
+func RebootRequiredListen() (target <-chan RebootRequired, remove func()) {
+	initRebootRequiredWatcher()
+
+	return rebootRequiredWatcherRunner.listen()
+}
+
+// RebootRequiredState returns whether a reboot is required once.
+func RebootRequiredState() RebootRequired {
+	initRebootRequiredWatcher()
+
+	return rebootRequiredWatcherRunner.getState()
+}
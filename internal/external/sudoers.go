@@ -0,0 +1,16 @@
+package external
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// VisudoCheck checks the syntax of a sudoers file, returning the output of the
+// parser.
+func VisudoCheck(path string) (string, error) {
+	c := exec.Command("visudo", "-c", "-f", path)
+	c.Env = append(os.Environ(), "LANG=C.UTF-8")
+	out, err := c.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
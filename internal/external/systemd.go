@@ -0,0 +1,23 @@
+package external
+
+import "github.com/willoma/keepakonf/internal/status"
+
+// Systemctl runs systemctl, sending its output to receiver.
+func Systemctl(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "systemctl", args...)
+}
+
+// Hostnamectl runs hostnamectl, sending its output to receiver.
+func Hostnamectl(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "hostnamectl", args...)
+}
+
+// Timedatectl runs timedatectl, sending its output to receiver.
+func Timedatectl(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "timedatectl", args...)
+}
+
+// Shutdown runs shutdown, sending its output to receiver.
+func Shutdown(receiver func(status.Status, string, status.Detail), args ...string) bool {
+	return execToMessage(receiver, []string{}, "shutdown", args...)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/zishang520/socket.io/v2/socket"
@@ -12,13 +13,16 @@ import (
 	"github.com/willoma/keepakonf/frontend"
 	"github.com/willoma/keepakonf/internal/client"
 	"github.com/willoma/keepakonf/internal/data"
+	"github.com/willoma/keepakonf/internal/external"
 	"github.com/willoma/keepakonf/internal/log"
+	"github.com/willoma/keepakonf/internal/status"
 )
 
 func Run(port int) (io.Closer, error) {
 	io := socket.NewServer(nil, nil)
 	log.SetIO(io.Sockets())
 	data := data.New(io)
+	go watchRebootRequired(io)
 
 	io.On("connection", func(clients ...any) {
 		client.Serve(clients[0].(*socket.Socket), io.Sockets(), data)
@@ -46,3 +50,23 @@ func Run(port int) (io.Closer, error) {
 
 	return s, nil
 }
+
+// watchRebootRequired logs when a reboot becomes required and sends the
+// reboot status to all clients.
+func watchRebootRequired(io *socket.Server) {
+	states, _ := external.RebootRequiredListen()
+
+	var required bool
+	for state := range states {
+		if state.Required && !required {
+			msg := "Reboot required"
+			if len(state.Packages) > 0 {
+				msg += " by " + strings.Join(state.Packages, ", ")
+			}
+			log.Info(msg, "reboot", status.StatusTodo, "", "", "", nil)
+		}
+		required = state.Required
+
+		io.Sockets().Emit("reboot required", state)
+	}
+}
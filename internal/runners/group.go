@@ -53,6 +53,12 @@ func (g *Group) Apply() {
 	}
 }
 
+// RefreshVariables updates the variables of all instructions, for instance
+// after a global variable has changed.
+func (g *Group) RefreshVariables() {
+	g.updateStatusAndVariables()
+}
+
 func (g *Group) GetInstruction(id string) (Instruction, bool) {
 	for _, i := range g.Instructions {
 		if ins, ok := i.getInstruction(id); ok {
@@ -172,6 +172,7 @@ func instructionCommandFromMap(mapped map[string]any, vars map[string]string, gr
 
 	command, _ := mapped["command"].(string)
 	parameters, _ := mapped["parameters"].(map[string]any)
+	command, parameters = commands.Migrate(command, parameters)
 
 	i := &instructionCommand{
 		ID:           id,
@@ -180,6 +181,6 @@ func instructionCommandFromMap(mapped map[string]any, vars map[string]string, gr
 		outVariables: map[string]string{},
 		group:        grp,
 	}
-	i.command = commands.Init(command, parameters, vars, i.updateStatus)
+	i.command = commands.Init(command, grp.ID, parameters, vars, i.updateStatus)
 	return i
 }
@@ -5,11 +5,15 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
+	"sync"
 )
 
 var (
-	globalMap Variables
-	Global    []Variable
+	globalMap       Variables
+	global          []Variable
+	globalMu        sync.RWMutex
+	globalListeners []func([]Variable)
 )
 
 func init() {
@@ -33,7 +37,7 @@ func init() {
 	}
 	globalMap.Define("osdistribution", string(bytes.TrimSpace(distro)))
 
-	Global = []Variable{
+	global = []Variable{
 		{
 			Name:        "hostname",
 			Description: "Name of the computer",
@@ -53,5 +57,44 @@ func init() {
 }
 
 func GlobalMap() Variables {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
 	return globalMap.Clone()
 }
+
+// Global returns the list of global variables, with their descriptions.
+func Global() []Variable {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return slices.Clone(global)
+}
+
+// SetGlobal changes the value of an existing global variable, and notifies
+// the listeners if it has changed.
+func SetGlobal(name, value string) {
+	globalMu.Lock()
+	i := slices.IndexFunc(global, func(v Variable) bool {
+		return v.Name == name
+	})
+	if i == -1 || global[i].Value == value {
+		globalMu.Unlock()
+		return
+	}
+	global[i].Value = value
+	globalMap.Define(name, value)
+	list := slices.Clone(global)
+	listeners := slices.Clone(globalListeners)
+	globalMu.Unlock()
+
+	for _, listener := range listeners {
+		listener(list)
+	}
+}
+
+// OnGlobalChange registers a function called with the new list of global
+// variables each time one of them changes.
+func OnGlobalChange(listener func([]Variable)) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalListeners = append(globalListeners, listener)
+}